@@ -0,0 +1,67 @@
+package zappaclang
+
+import (
+	"container/list"
+	"sync"
+)
+
+// parseCacheSize is the number of inputs ParseCached remembers
+const parseCacheSize = 256
+
+type parseCacheEntry struct {
+	input string
+	nodes []Node
+	err   error
+}
+
+// parseCache is a least recently used cache of parse results
+type parseCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+var defaultParseCache = newParseCache(parseCacheSize)
+
+func newParseCache(size int) *parseCache {
+	return &parseCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (pc *parseCache) parse(input string) ([]Node, error) {
+	pc.mu.Lock()
+	if element, ok := pc.entries[input]; ok {
+		pc.order.MoveToFront(element)
+		entry := element.Value.(*parseCacheEntry)
+		pc.mu.Unlock()
+		return entry.nodes, entry.err
+	}
+	pc.mu.Unlock()
+
+	// Parse without holding the lock, a concurrent parse of the same input just gives the same result
+	nodes, err := Parse(input)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if _, ok := pc.entries[input]; !ok {
+		pc.entries[input] = pc.order.PushFront(&parseCacheEntry{input, nodes, err})
+		if pc.order.Len() > pc.size {
+			oldest := pc.order.Back()
+			pc.order.Remove(oldest)
+			delete(pc.entries, oldest.Value.(*parseCacheEntry).input)
+		}
+	}
+
+	return nodes, err
+}
+
+// ParseCached parses the input like Parse, remembering the results of recently parsed inputs. The same nodes are
+// given to every caller of the same input, so they must not be modified.
+func ParseCached(input string) ([]Node, error) {
+	return defaultParseCache.parse(input)
+}
@@ -0,0 +1,72 @@
+package zappaclang
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseCached(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	for value, expected := range map[string]string{"1": "3", "2": "5", "3": "7"} {
+		zs.Variables["$foo"] = newNumber(-1, value, Dec)
+
+		nodes, err := ParseCached("$foo * 2 + 1")
+		if err != nil {
+			t.Fatalf("got\n\t%v", err)
+		}
+
+		parsed, _ := Parse("$foo * 2 + 1")
+		if fmt.Sprint(nodes) != fmt.Sprint(parsed) {
+			t.Errorf("got\n\t%v\nexpected\n\t%v", nodes, parsed)
+		}
+
+		result, err := zs.Exec(nodes, true)
+		if err != nil {
+			t.Errorf("$foo = %s: got\n\t%v", value, err)
+		}
+		if result != expected {
+			t.Errorf("$foo = %s: got\n\t%s\nexpected\n\t%s", value, result, expected)
+		}
+	}
+
+	if _, err := ParseCached("1 +"); err == nil {
+		t.Errorf("expected an error for 1 +")
+	}
+	if _, err := ParseCached("1 +"); err == nil {
+		t.Errorf("expected the cached error for 1 +")
+	}
+}
+
+func TestParseCacheEviction(t *testing.T) {
+	pc := newParseCache(2)
+	_, _ = pc.parse("1")
+	_, _ = pc.parse("2")
+	_, _ = pc.parse("1")
+	_, _ = pc.parse("3")
+
+	if _, ok := pc.entries["2"]; ok {
+		t.Errorf("expected the least recently used input to be evicted")
+	}
+	if _, ok := pc.entries["1"]; !ok {
+		t.Errorf("expected the recently used input to be kept")
+	}
+	if pc.order.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", pc.order.Len())
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = Parse("($foo + 0xff) * 2 // 3")
+	}
+}
+
+func BenchmarkParseCached(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = ParseCached("($foo + 0xff) * 2 // 3")
+	}
+}
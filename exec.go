@@ -1,13 +1,21 @@
 package zappaclang
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
+	"math/big"
 	"os"
 	"path"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,6 +25,62 @@ var StoragePath = "."
 
 var emptyNumber = newNumber(-1, "", Dec)
 
+// ErrorResultTooLarge is given when a result exceeds MaxResultDigits
+var ErrorResultTooLarge = errors.New("result too large")
+
+// lastAssignedVariable references the most recently assigned variable
+const lastAssignedVariable = "$$"
+
+// profileSeparator separates the profile and variable names in $profile::name
+const profileSeparator = "::"
+
+// ErrorInternalExec is given when executing runs into a bug instead of crashing
+var ErrorInternalExec = errors.New("internal error while executing, this is a bug")
+
+// ErrorUnbalancedParentheses is given when executing nodes with a ( that is never closed
+var ErrorUnbalancedParentheses = errors.New("unbalanced parentheses")
+
+// ErrorInfinite is given when a calculation results in infinity
+var ErrorInfinite = errors.New("result is infinite")
+
+// ErrorNaN is given when a calculation results in NaN
+var ErrorNaN = errors.New("result is not a number")
+
+// ErrorProfileNameRequired is given when saving or loading a profile without a name
+var ErrorProfileNameRequired = errors.New("profile name required")
+
+// PositionedError is an error with the position in the input where it was noticed
+type PositionedError struct {
+	Err error
+	// Pos is -1 when the position is not known, e.g. for most errors while executing
+	Pos Pos
+}
+
+func (pe *PositionedError) Error() string {
+	return pe.Err.Error()
+}
+
+func (pe *PositionedError) Unwrap() error {
+	return pe.Err
+}
+
+// OperationError is given when an operator or function can't be used with its operands, e.g. popcount(1.5)
+type OperationError struct {
+	// Operation is the operator or function, e.g. ** or popcount()
+	Operation string
+	// Operand is the offending value, empty when there isn't a single one
+	Operand string
+	// Reason describes what the operation requires, e.g. "requires an integer"
+	Reason string
+}
+
+func (oe *OperationError) Error() string {
+	if oe.Operand == "" {
+		return fmt.Sprintf("%s %s", oe.Operation, oe.Reason)
+	}
+	return fmt.Sprintf("%s %s, got %s", oe.Operation, oe.Reason, oe.Operand)
+}
+
 // OnSaveCallback is the type of the OnSave callback
 type OnSaveCallback func()
 
@@ -24,35 +88,246 @@ type OnSaveCallback func()
 type ZappacState struct {
 	Variables map[string]NumberNode `yaml:"variables"`
 	OnSave    OnSaveCallback        `yaml:"-"`
+	// warnings collected during the current ExecResult
+	warnings []string
+	// lastAssigned is the name of the most recently assigned variable, referenced with $$
+	lastAssigned string
+	// lastError is the error from the most recent Eval or ExecResult
+	lastError *PositionedError
+	// previousResult is the result of the previous calculation, used as the left operand of a leading operator
+	previousResult NumberNode
+	// cancel stops the evaluation when it's closed, e.g. after the timeout of EvalTimeout
+	cancel <-chan struct{}
+
+	// FixedDecimals pads or truncates decimal results to this many fractional digits for display, 0 disables it
+	FixedDecimals int `yaml:"-"`
+	// Notation is used to display decimal results, Scientific and Engineering use FixedDecimals as their precision
+	// when set
+	Notation Notation `yaml:"-"`
+	// ShowPreviousValue shows the old value too when assigning to an existing variable, e.g. $foo: 5 -> 12
+	ShowPreviousValue bool `yaml:"-"`
+	// GroupBitDigits separates hex and binary results in groups of 4 digits for display, e.g. 0xffff_ffff
+	GroupBitDigits bool `yaml:"-"`
+	// GroupSeparator is put between the groups of digits with GroupBitDigits, 0 defaults to _
+	GroupSeparator rune `yaml:"-"`
+	// MinHexDigits pads hex results with leading zeros to at least this many digits for display, e.g. 0x0f for 2
+	MinHexDigits int `yaml:"-"`
+	// MinBinDigits pads binary results with leading zeros to at least this many digits for display, e.g. b0011 for 4
+	MinBinDigits int `yaml:"-"`
+	// BitWidth is the width of integers for bitwise functions, 0 defaults to 64
+	BitWidth int `yaml:"-"`
+	// MaxResultDigits aborts calculations with results with more integer digits than this, 0 disables it
+	MaxResultDigits int `yaml:"-"`
+	// FractionMode keeps + - * / and integer ** exact, showing results as reduced fractions like 1/3
+	FractionMode bool `yaml:"-"`
+	// TreatUnknownVarsAsZero makes unknown variables evaluate to 0 instead of giving an error
+	TreatUnknownVarsAsZero bool `yaml:"-"`
+	// ProfileReferences allows reading variables from saved profiles with $profile::name
+	ProfileReferences bool `yaml:"-"`
+	// StrictBitwise warns when & | ^ combine numbers written in different number systems, e.g. 0xff & 10
+	StrictBitwise bool `yaml:"-"`
+	// EqualityEpsilon makes == and != treat values at most this far apart as equal, 0 compares exactly
+	EqualityEpsilon float64 `yaml:"-"`
+	// ResultFormatter renders the results instead of the built-in formatting when set, e.g. for currencies
+	ResultFormatter func(value float64, system NumberSystem) string `yaml:"-"`
+	// OutputCase is used for the digits and prefixes of hex and binary results, e.g. 0XFF with Uppercase
+	OutputCase LetterCase `yaml:"-"`
+	// AllowedCommands limits the functions and commands that can be used to these names, e.g. abs and hex, nil allows
+	// all of them. Numbers, variables and operators are always allowed.
+	AllowedCommands []string `yaml:"-"`
+	// WarnLeadingZeroOctal adds a warning whenever a number like 0755 is interpreted as octal
+	WarnLeadingZeroOctal bool `yaml:"-"`
 }
 
-func getProfileFile(profile string) string {
-	return fmt.Sprintf("%s/%s.json", StoragePath, profile)
+func getProfileFile(profile string) (string, error) {
+	if profile == "" {
+		return "", ErrorProfileNameRequired
+	}
+	return fmt.Sprintf("%s/%s.json", StoragePath, profile), nil
 }
 
 func (zs *ZappacState) load(profile string) string {
-	fp := getProfileFile(profile)
+	fp, err := getProfileFile(profile)
+	if err != nil {
+		return err.Error()
+	}
 
-	contents, err := os.ReadFile(fp)
+	f, err := os.Open(fp)
 	if err != nil {
 		return err.Error()
 	}
+	defer f.Close()
 
-	err = yaml.Unmarshal(contents, &zs)
+	before := make(map[string]string, len(zs.Variables))
+	for name, value := range zs.Variables {
+		before[name] = value.String()
+	}
+
+	err = zs.read(f)
 	if err != nil {
 		return err.Error()
 	}
 
-	return fmt.Sprintf("Loaded %s", profile)
+	changed := changedVariables(before, zs.Variables)
+	if changed == 0 {
+		return fmt.Sprintf("Loaded %s (no changes)", profile)
+	} else if changed == 1 {
+		return fmt.Sprintf("Loaded %s (1 variable changed)", profile)
+	}
+	return fmt.Sprintf("Loaded %s (%d variables changed)", profile, changed)
+}
+
+// changedVariables counts the variables that were added, removed, or got a different value compared to before
+func changedVariables(before map[string]string, after map[string]NumberNode) int {
+	changed := 0
+	for name, value := range after {
+		if previous, ok := before[name]; !ok || previous != value.String() {
+			changed++
+		}
+	}
+
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed++
+		}
+	}
+
+	return changed
+}
+
+// read unmarshals the state saved by save from the reader
+func (zs *ZappacState) read(r io.Reader) error {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(contents, &zs)
+}
+
+// readProfile reads a saved profile without touching any current state
+func readProfile(profile string) (*ZappacState, error) {
+	fp, err := getProfileFile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewZappacStateFromReader(f)
+}
+
+// ValidateProfile checks that a saved profile can be read and that all of its variables are valid numbers, without
+// loading it
+func ValidateProfile(name string) error {
+	state, err := readProfile(name)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(state.Variables))
+	for variable := range state.Variables {
+		names = append(names, variable)
+	}
+	sort.Strings(names)
+
+	for _, variable := range names {
+		value := state.Variables[variable]
+		nodes, err := Parse(value.Value)
+		if err == nil && len(nodes) == 2 && nodes[0].Type() == NodeNumber {
+			num, _ := nodes[0].(NumberNode)
+			if num.Value == value.Value && num.Unit == "" && num.System == value.System {
+				continue
+			}
+		}
+
+		// FractionMode saves exact fractions like 1/3, which are read back with big.Rat rather than parsed
+		if _, ok := new(big.Rat).SetString(value.Value); ok && value.System == Dec && strings.Contains(value.Value, "/") {
+			continue
+		}
+
+		return fmt.Errorf("invalid value %q for %s in profile %s", value.Value, variable, name)
+	}
+
+	return nil
+}
+
+// DiffProfiles compares the variables of two saved profiles, giving the values of each variable that differs as
+// [a, b]. Variables only in one of the profiles have an empty value for the other.
+func DiffProfiles(a, b string) (map[string][2]string, error) {
+	stateA, err := readProfile(a)
+	if err != nil {
+		return nil, err
+	}
+
+	stateB, err := readProfile(b)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string][2]string{}
+	for name, valueA := range stateA.Variables {
+		valueB, ok := stateB.Variables[name]
+		if !ok {
+			diff[name] = [2]string{valueA.String(), ""}
+		} else if valueA.String() != valueB.String() {
+			diff[name] = [2]string{valueA.String(), valueB.String()}
+		}
+	}
+
+	for name, valueB := range stateB.Variables {
+		if _, ok := stateA.Variables[name]; !ok {
+			diff[name] = [2]string{"", valueB.String()}
+		}
+	}
+
+	return diff, nil
+}
+
+// clone copies the state with its own variables, so changing either one doesn't affect the other
+func (zs *ZappacState) clone() ZappacState {
+	copied := *zs
+	copied.warnings = nil
+	copied.Variables = make(map[string]NumberNode, len(zs.Variables))
+	for name, value := range zs.Variables {
+		copied.Variables[name] = value
+	}
+	return copied
+}
+
+// StateSnapshot is an in-memory copy of the variables and settings of a ZappacState, see Snapshot
+type StateSnapshot struct {
+	state ZappacState
+}
+
+// Snapshot copies the variables and settings, e.g. to undo changes with RestoreSnapshot. Nothing is saved to disk.
+func (zs *ZappacState) Snapshot() StateSnapshot {
+	return StateSnapshot{zs.clone()}
+}
+
+// RestoreSnapshot sets the variables and settings back to what they were when the snapshot was taken. The same
+// snapshot can be restored any number of times.
+func (zs *ZappacState) RestoreSnapshot(snapshot StateSnapshot) {
+	*zs = snapshot.state.clone()
 }
 
 func (zs *ZappacState) clear() {
 	zs.Variables = map[string]NumberNode{}
+	zs.lastAssigned = ""
+	zs.previousResult = emptyNumber
 }
 
 func (zs *ZappacState) save(profile string) string {
-	fp := getProfileFile(profile)
+	fp, err := getProfileFile(profile)
+	if err != nil {
+		return err.Error()
+	}
 
+	// yaml.v3 sorts map keys, so the variables are always saved in the same order and files diff cleanly
 	contents, err := yaml.Marshal(&zs)
 	if err != nil {
 		return err.Error()
@@ -73,6 +348,60 @@ func (zs *ZappacState) save(profile string) string {
 	return fmt.Sprintf("Saved %s", profile)
 }
 
+// warn adds a non-fatal warning to the result of the current ExecResult
+func (zs *ZappacState) warn(format string, args ...any) {
+	zs.warnings = append(zs.warnings, fmt.Sprintf(format, args...))
+}
+
+// warnOctalLiterals warns about every number literal interpreted as octal because of its leading zero
+func (zs *ZappacState) warnOctalLiterals(nodes []Node) {
+	for _, node := range nodes {
+		if node.Type() != NodeNumber {
+			continue
+		}
+
+		nn, _ := node.(NumberNode)
+		if nn.System != Oct {
+			continue
+		}
+
+		if i64, err := nn.toInt64(); err == nil {
+			zs.warn("interpreted %s as octal (%d)", nn.Value, i64)
+		}
+	}
+}
+
+// cancelled tells if the evaluation should stop, checked in the loops that can run for long
+func (zs *ZappacState) cancelled() bool {
+	select {
+	case <-zs.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// bitWidth gives the configured BitWidth, falling back to 64
+func (zs *ZappacState) bitWidth() int {
+	if zs.BitWidth <= 0 || zs.BitWidth > 64 {
+		return 64
+	}
+	return zs.BitWidth
+}
+
+// toWidth gives the bits of the value when truncated to the configured BitWidth
+func (zs *ZappacState) toWidth(value int64) uint64 {
+	return truncateToWidth(value, zs.bitWidth())
+}
+
+// truncateToWidth gives the two's complement bits of the value when truncated to width bits
+func truncateToWidth(value int64, width int) uint64 {
+	if width == 64 {
+		return uint64(value)
+	}
+	return uint64(value) & (1<<width - 1)
+}
+
 func findNext(nodes []Node, types []NodeType) int {
 	for idx := 0; idx < len(nodes); idx++ {
 		for _, typ := range types {
@@ -85,6 +414,24 @@ func findNext(nodes []Node, types []NodeType) int {
 	return -1
 }
 
+// findNextTopLevel is like findNext, but skips anything within parenthesis
+func findNextTopLevel(nodes []Node, types []NodeType) int {
+	parenthesis := 0
+
+	for idx := 0; idx < len(nodes); idx++ {
+		typ := nodes[idx].Type()
+		if typ == NodeLParen {
+			parenthesis++
+		} else if typ == NodeRParen {
+			parenthesis--
+		} else if parenthesis == 0 && IsNodeType(nodes[idx], types) {
+			return idx
+		}
+	}
+
+	return -1
+}
+
 // Find closing parenthesis for the LParen
 func findClosing(nodes []Node) int {
 	parenthesis := 0
@@ -121,18 +468,134 @@ func (zs *ZappacState) readValue(node Node) (NumberNode, error) {
 	typ := node.Type()
 	if typ == NodeVariable {
 		nv, _ := node.(VariableNode)
-		val, ok := zs.Variables[nv.Name]
+		name := nv.Name
+		if name == lastAssignedVariable {
+			if zs.lastAssigned == "" {
+				return emptyNumber, fmt.Errorf("%s used before any variable was assigned", lastAssignedVariable)
+			}
+			name = zs.lastAssigned
+		}
+
+		if profile, variable, ok := strings.Cut(name[1:], profileSeparator); ok {
+			return zs.readProfileVariable(profile, "$"+variable)
+		}
+
+		val, ok := zs.Variables[name]
 		if !ok {
+			if zs.TreatUnknownVarsAsZero {
+				return newNumber(-1, "0", Dec), nil
+			}
 			return emptyNumber, fmt.Errorf("unknown variable %s", nv.Name)
 		}
 		return val, nil
 	}
 
+	if typ == NodeString {
+		return emptyNumber, fmt.Errorf("%s is a string, not a number", node.String())
+	}
+
 	// typ == NodeNumber
 	n, _ := node.(NumberNode)
 	return n, nil
 }
 
+// readProfileVariable reads a variable from a saved profile, without changing the current state
+func (zs *ZappacState) readProfileVariable(profile, name string) (NumberNode, error) {
+	if !zs.ProfileReferences {
+		return emptyNumber, fmt.Errorf("%s%s%s can't be read, profile references are not enabled", profile, profileSeparator, name[1:])
+	}
+
+	state, err := readProfile(profile)
+	if err != nil {
+		return emptyNumber, fmt.Errorf("can't read profile %s: %w", profile, err)
+	}
+
+	val, ok := state.Variables[name]
+	if !ok {
+		return emptyNumber, fmt.Errorf("unknown variable %s in profile %s", name, profile)
+	}
+	return val, nil
+}
+
+// fractionOperators are the operators FractionMode can calculate exactly
+var fractionOperators = []NodeType{NodeAdd, NodeSub, NodeMult, NodeDiv, NodeExp}
+
+// maxExactBits limits the size of the numerator and denominator of exact ** results, so a single calculation can't
+// use unlimited memory even when MaxResultDigits is off
+const maxExactBits = 1 << 22
+
+// calculateFraction calculates the result of an operator exactly with big.Rat
+func (zs *ZappacState) calculateFraction(leftNum NumberNode, op OperatorNode, rightNum NumberNode) (NumberNode, error) {
+	l, err := leftNum.toRat()
+	if err != nil {
+		return emptyNumber, err
+	}
+
+	r, err := rightNum.toRat()
+	if err != nil {
+		return emptyNumber, err
+	}
+
+	opType := op.Type()
+	result := new(big.Rat)
+	if opType == NodeAdd {
+		result.Add(l, r)
+	} else if opType == NodeSub {
+		result.Sub(l, r)
+	} else if opType == NodeMult {
+		result.Mul(l, r)
+	} else if opType == NodeDiv {
+		if r.Sign() == 0 {
+			return emptyNumber, fmt.Errorf("division by zero")
+		}
+		result.Quo(l, r)
+	} else if opType == NodeExp {
+		if !r.IsInt() || !r.Num().IsInt64() {
+			return emptyNumber, &OperationError{op.String(), rightNum.String(), "requires an exponent that fits in 64 bits in fraction mode"}
+		}
+
+		exp := r.Num().Int64()
+		if exp < 0 {
+			if l.Sign() == 0 {
+				return emptyNumber, fmt.Errorf("division by zero")
+			}
+			l.Inv(l)
+			exp = -exp
+		}
+
+		// Check the size before calculating it, the bit lengths give a lower bound, e.g. 1 bit per step for 3 ** n
+		numBits, denomBits := float64(l.Num().BitLen()-1), float64(l.Denom().BitLen()-1)
+		if (numBits+denomBits)*float64(exp) > maxExactBits {
+			return emptyNumber, ErrorResultTooLarge
+		}
+		if zs.MaxResultDigits > 0 && (numBits-denomBits-1)*float64(exp)*math.Log10(2) > float64(zs.MaxResultDigits) {
+			return emptyNumber, ErrorResultTooLarge
+		}
+
+		if zs.cancelled() {
+			return emptyNumber, context.Canceled
+		}
+
+		num := new(big.Int).Exp(l.Num(), big.NewInt(exp), nil)
+		denom := new(big.Int).Exp(l.Denom(), big.NewInt(exp), nil)
+		result.SetFrac(num, denom)
+	} else {
+		return emptyNumber, fmt.Errorf("unknown operation %s", op)
+	}
+
+	if zs.MaxResultDigits > 0 && ratIntegerDigits(result) > zs.MaxResultDigits {
+		return emptyNumber, ErrorResultTooLarge
+	}
+
+	return newNumber(-1, result.RatString(), Dec), nil
+}
+
+// ratIntegerDigits counts the digits before the decimal point of an exact number
+func ratIntegerDigits(r *big.Rat) int {
+	integer := new(big.Int).Quo(r.Num(), r.Denom())
+	return len(integer.Abs(integer).String())
+}
+
 func (zs *ZappacState) calculate(left Node, op OperatorNode, right Node) (NumberNode, error) {
 	// Each left or right can be a variable reference, or a number
 	leftNum, err := zs.readValue(left)
@@ -140,6 +603,25 @@ func (zs *ZappacState) calculate(left Node, op OperatorNode, right Node) (Number
 		return emptyNumber, err
 	}
 
+	if zs.FractionMode && IsNodeType(op, fractionOperators) {
+		rightNum, err := zs.readValue(right)
+		if err != nil {
+			return emptyNumber, err
+		}
+
+		// Fractional exponents like 4 ** (1/2) can't be calculated exactly, they use floats instead
+		if exp, err := rightNum.toRat(); op.Type() != NodeExp || (err == nil && exp.IsInt()) {
+			unit, err := combineUnits(leftNum.Unit, rightNum.Unit)
+			if err != nil {
+				return emptyNumber, err
+			}
+
+			value, err := zs.calculateFraction(leftNum, op, rightNum)
+			value.Unit = unit
+			return value, err
+		}
+	}
+
 	l, err := leftNum.toFloat64()
 	if err != nil {
 		return emptyNumber, err
@@ -155,7 +637,31 @@ func (zs *ZappacState) calculate(left Node, op OperatorNode, right Node) (Number
 		return emptyNumber, err
 	}
 
+	unit, err := combineUnits(leftNum.Unit, rightNum.Unit)
+	if err != nil {
+		return emptyNumber, err
+	}
+
+	if zs.StrictBitwise && IsNodeType(op, strictBitwiseOperators) {
+		leftSystem, leftOk := declaredSystem(left, leftNum)
+		rightSystem, rightOk := declaredSystem(right, rightNum)
+		if leftOk && rightOk && leftSystem != rightSystem {
+			zs.warn("mixing %s and %s in bitwise op %s %s %s", systemNames[leftSystem], systemNames[rightSystem], leftNum, op, rightNum)
+		}
+	}
+
 	opType := op.Type()
+	if r == 0 && (opType == NodeDiv || opType == NodeFdiv || opType == NodeMod) {
+		return emptyNumber, fmt.Errorf("division by zero")
+	}
+
+	if opType == NodeFdiv || opType == NodeMod {
+		if value, ok := integerDivision(leftNum, opType, rightNum); ok {
+			value.Unit = unit
+			return value, nil
+		}
+	}
+
 	var result float64
 	if opType == NodeAdd {
 		result = l + r
@@ -187,75 +693,281 @@ func (zs *ZappacState) calculate(left Node, op OperatorNode, right Node) (Number
 		return emptyNumber, fmt.Errorf("unknown operation %s", op)
 	}
 
-	resultStr := strconv.FormatFloat(result, 'f', -1, 64)
-	// fmt.Printf("%s %s %s = %s\n", left, op, right, resultStr)
+	if err := checkFinite(result); err != nil {
+		return emptyNumber, fmt.Errorf("%s %s %s: %w", leftNum, op, rightNum, err)
+	}
 
-	return newNumber(-1, resultStr, Dec), nil
-}
+	if l == math.Trunc(l) && r == math.Trunc(r) {
+		if opType == NodeDiv && r != 0 && math.Mod(l, r) != 0 {
+			zs.warn("%s / %s has a remainder, the result is not exact", leftNum, rightNum)
+		} else if !exactFloat(leftNum, l) {
+			zs.warn("precision lost, %s is larger than 2^53", leftNum)
+		} else if !exactFloat(rightNum, r) {
+			zs.warn("precision lost, %s is larger than 2^53", rightNum)
+		} else if math.Abs(result) > 1<<53 {
+			zs.warn("precision lost, %s %s %s is larger than 2^53", leftNum, op, rightNum)
+		}
+	}
 
-func (zs *ZappacState) calculateNodes(nodes []Node, operatorPos int) (result []Node, err error) {
-	op, _ := nodes[operatorPos].(OperatorNode)
+	if zs.MaxResultDigits > 0 {
+		if integerDigits(result) > zs.MaxResultDigits {
+			return emptyNumber, ErrorResultTooLarge
+		}
+	}
 
-	left := operatorPos - 1
-	right := operatorPos + 1
+	value := newFloatNumber(result)
+	value.Unit = unit
+	return value, nil
+}
 
-	// Calculate it away and replace in node tree
-	value, err := zs.calculate(nodes[left], op, nodes[right])
-	if err != nil {
-		return
+// exactFloat tells if an integer is the same after reading it as a float64, e.g. 9007199254740993 is not
+func exactFloat(num NumberNode, f64 float64) bool {
+	i, ok := num.toBigInt()
+	if !ok || math.IsInf(f64, 0) {
+		return true
 	}
-	result = replace(nodes, left, right, value)
-	return
+
+	rounded, _ := big.NewFloat(f64).Int(nil)
+	return i.Cmp(rounded) == 0
 }
 
-// (parenthesis & exponent) (multiply & divide) (add & substract)
-func (zs *ZappacState) pemdas(nodes []Node) (output string, err error) {
-	// fmt.Printf("pemdas %+v\n", nodes)
+// integerDivision calculates // and % exactly when both operands are integers, float64 loses precision past 2^53
+func integerDivision(leftNum NumberNode, opType NodeType, rightNum NumberNode) (NumberNode, bool) {
+	l, err := leftNum.toRat()
+	if err != nil || !l.IsInt() {
+		return emptyNumber, false
+	}
 
-	output = ""
+	r, err := rightNum.toRat()
+	if err != nil || !r.IsInt() || r.Sign() == 0 {
+		return emptyNumber, false
+	}
 
-	var next int
-	for {
-		if err != nil {
-			return
-		}
+	// Quo and Rem truncate like math.Mod, floor division rounds towards negative infinity instead
+	quotient, remainder := new(big.Int).QuoRem(l.Num(), r.Num(), new(big.Int))
+	if opType == NodeMod {
+		return newNumber(-1, remainder.String(), Dec), true
+	}
 
-		// If the node list is only 1 item, it must be a number or variable
-		if len(nodes) == 1 {
-			if nodes[0].Type() == NodeVariable {
-				var val NumberNode
-				val, err = zs.readValue(nodes[0])
-				output = val.String()
-			} else {
-				output = nodes[0].String()
-			}
-			return
-		}
+	if remainder.Sign() != 0 && remainder.Sign() != r.Num().Sign() {
+		quotient.Sub(quotient, big.NewInt(1))
+	}
+	return newNumber(-1, quotient.String(), Dec), true
+}
 
-		// Parenthesis & exponent
-		next = findNext(nodes, []NodeType{NodeLParen, NodeExp})
-		if next != -1 {
-			node := nodes[next]
-			typ := node.Type()
+// strictBitwiseOperators are checked for mixed number systems with StrictBitwise
+var strictBitwiseOperators = []NodeType{NodeAnd, NodeOr, NodeXor}
 
-			if typ == NodeLParen {
-				// Need to find closing parenthesis
-				closing := next + findClosing(nodes[next:])
+// systemNames are the number systems as written in messages
+var systemNames = map[NumberSystem]string{
+	Dec: "decimal",
+	Hex: "hex",
+	Bin: "binary",
+	Oct: "octal",
+}
 
-				// Calculate it away and replace in node tree
-				var result string
-				// fmt.Printf("Going in %d-%d of %+v\n", next, closing, nodes)
-				result, err = zs.pemdas(nodes[next+1 : closing])
-				nodes = replace(nodes, next, closing, newNumber(-1, result, Dec))
-			} else if typ == NodeExp {
-				nodes, err = zs.calculateNodes(nodes, next)
-			}
-			continue
-		}
+// declaredSystem gives the number system the operand was written or stored in, intermediate results of calculations
+// are always decimal so they have none
+func declaredSystem(node Node, num NumberNode) (NumberSystem, bool) {
+	if node.Type() == NodeNumber && node.Position() < 0 {
+		return Dec, false
+	}
+	return num.System, true
+}
 
-		// multiply & divide (and // % & | ^ ~ << >>)
-		next = findNext(nodes, []NodeType{NodeMult, NodeDiv, NodeFdiv, NodeMod, NodeAnd, NodeOr, NodeXor, NodeInv, NodeLShift, NodeRShift})
-		if next != -1 {
+// compare gives the result of a single comparison
+func (zs *ZappacState) compare(left Node, op Node, right Node) (bool, error) {
+	leftNum, err := zs.readValue(left)
+	if err != nil {
+		return false, err
+	}
+
+	rightNum, err := zs.readValue(right)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := combineUnits(leftNum.Unit, rightNum.Unit); err != nil {
+		return false, err
+	}
+
+	l, err := leftNum.toRat()
+	if err != nil {
+		return false, err
+	}
+
+	r, err := rightNum.toRat()
+	if err != nil {
+		return false, err
+	}
+
+	cmp := l.Cmp(r)
+	if zs.EqualityEpsilon > 0 && IsNodeType(op, []NodeType{NodeEq, NodeNe}) {
+		diff := new(big.Rat).Sub(l, r)
+		if diff.Abs(diff).Cmp(new(big.Rat).SetFloat64(zs.EqualityEpsilon)) <= 0 {
+			cmp = 0
+		}
+	}
+
+	switch op.Type() {
+	case NodeLt:
+		return cmp < 0, nil
+	case NodeLte:
+		return cmp <= 0, nil
+	case NodeGt:
+		return cmp > 0, nil
+	case NodeGte:
+		return cmp >= 0, nil
+	case NodeEq:
+		return cmp == 0, nil
+	case NodeNe:
+		return cmp != 0, nil
+	}
+
+	return false, fmt.Errorf("unknown comparison %s", op)
+}
+
+// compareChain compares the chain of comparisons starting at operatorPos like Python does, so 1 < $x < 10 means
+// 1 < $x and $x < 10, and replaces the chain with 1 or 0
+func (zs *ZappacState) compareChain(nodes []Node, operatorPos int) (result []Node, err error) {
+	start := operatorPos - 1
+	end := operatorPos + 1
+	matches := true
+
+	for pos := operatorPos; pos < len(nodes) && IsNodeType(nodes[pos], ComparisonNodes); pos += 2 {
+		var ok bool
+		ok, err = zs.compare(nodes[pos-1], nodes[pos], nodes[pos+1])
+		if err != nil {
+			return
+		}
+
+		matches = matches && ok
+		end = pos + 1
+	}
+
+	value := newFloatNumber(0)
+	if matches {
+		value = newFloatNumber(1)
+	}
+
+	result = replace(nodes, start, end, value)
+	return
+}
+
+// checkFinite makes sure a float result can be represented as a number
+func checkFinite(value float64) error {
+	if math.IsInf(value, 0) {
+		return ErrorInfinite
+	}
+	if math.IsNaN(value) {
+		return ErrorNaN
+	}
+	return nil
+}
+
+// integerDigits counts the digits before the decimal point
+func integerDigits(value float64) int {
+	return len(strconv.FormatFloat(math.Abs(math.Trunc(value)), 'f', 0, 64))
+}
+
+// combineUnits finds the unit for the result of a calculation between numbers with the given units
+func combineUnits(left, right string) (string, error) {
+	if left == "" {
+		return right, nil
+	}
+
+	if right == "" || left == right {
+		return left, nil
+	}
+
+	return "", fmt.Errorf("conflicting units %s and %s", left, right)
+}
+
+func (zs *ZappacState) calculateNodes(nodes []Node, operatorPos int) (result []Node, err error) {
+	op, _ := nodes[operatorPos].(OperatorNode)
+
+	left := operatorPos - 1
+	right := operatorPos + 1
+
+	// Calculate it away and replace in node tree
+	value, err := zs.calculate(nodes[left], op, nodes[right])
+	if err != nil {
+		return
+	}
+	result = replace(nodes, left, right, value)
+	return
+}
+
+// (parenthesis & exponent) (multiply & divide) (add & substract)
+func (zs *ZappacState) pemdas(nodes []Node) (output NumberNode, err error) {
+	// fmt.Printf("pemdas %+v\n", nodes)
+
+	output = emptyNumber
+
+	var next int
+	for {
+		if err != nil {
+			return
+		}
+		if zs.cancelled() {
+			err = context.Canceled
+			return
+		}
+
+		// If the node list is only 1 item, it must be a number or variable
+		if len(nodes) == 1 {
+			output, err = zs.readValue(nodes[0])
+			return
+		}
+
+		// Inline assignments take the value of everything following them in the same parenthesis
+		next = findNextTopLevel(nodes, []NodeType{NodeInlineAssign})
+		if next != -1 {
+			var value NumberNode
+			value, err = zs.pemdas(nodes[next+1:])
+			if err == nil {
+				assign, _ := nodes[next].(InlineAssignNode)
+				zs.Variables[assign.Target] = value
+				zs.lastAssigned = assign.Target
+				nodes = append(nodes[:next:next], value)
+			}
+			continue
+		}
+
+		// Functions & parenthesis, then exponent so the exponent can itself be e.g. (1 / 2)
+		next = findNext(nodes, []NodeType{NodeAbs, NodeFunction, NodeLParen})
+		if next == -1 {
+			next = findNext(nodes, []NodeType{NodeExp})
+		}
+		if next != -1 {
+			node := nodes[next]
+			typ := node.Type()
+
+			if typ == NodeLParen {
+				// Need to find closing parenthesis
+				offset := findClosing(nodes[next:])
+				if offset == -1 {
+					err = ErrorUnbalancedParentheses
+					return
+				}
+				closing := next + offset
+
+				// Calculate it away and replace in node tree
+				var result NumberNode
+				// fmt.Printf("Going in %d-%d of %+v\n", next, closing, nodes)
+				result, err = zs.pemdas(nodes[next+1 : closing])
+				nodes = replace(nodes, next, closing, result)
+			} else if typ == NodeExp {
+				nodes, err = zs.calculateNodes(nodes, next)
+			} else {
+				nodes, err = zs.callFunction(nodes, next)
+			}
+			continue
+		}
+
+		// multiply & divide (and // % & | ^ ~ << >>)
+		next = findNext(nodes, []NodeType{NodeMult, NodeDiv, NodeFdiv, NodeMod, NodeAnd, NodeOr, NodeXor, NodeInv, NodeLShift, NodeRShift})
+		if next != -1 {
 			nodes, err = zs.calculateNodes(nodes, next)
 			continue
 		}
@@ -266,6 +978,13 @@ func (zs *ZappacState) pemdas(nodes []Node) (output string, err error) {
 			continue
 		}
 
+		// Comparisons, everything else has been calculated so chains can be compared all at once
+		next = findNext(nodes, ComparisonNodes)
+		if next != -1 {
+			nodes, err = zs.compareChain(nodes, next)
+			continue
+		}
+
 		// Cut out EOF cleanly
 		if nodes[1].Type() == NodeEOF {
 			nodes = []Node{nodes[0]}
@@ -273,28 +992,191 @@ func (zs *ZappacState) pemdas(nodes []Node) (output string, err error) {
 		}
 
 		fmt.Printf("Unexpected end of pemdas: %+v\n", nodes)
-		return "ERROR", err
+		return NumberNode{NodeType: NodeNumber, Pos: -1, Value: "ERROR"}, err
+	}
+}
+
+// convertNumber formats the number in the given number system, non-decimal systems truncate to integers.
+// Negative numbers keep a leading minus like -0x7, use AtWidth for the two's complement bits.
+func convertNumber(num NumberNode, system NumberSystem) (string, error) {
+	if num.System == system {
+		return num.Value, nil
+	}
+
+	// Integers are converted exactly, float64 loses precision past 2^53
+	if i, ok := num.toBigInt(); ok {
+		return formatInteger(i, system), nil
+	}
+
+	f64, err := num.toFloat64()
+	if err != nil {
+		return "", err
+	}
+
+	if system != Dec {
+		i64 := int64(f64)
+		sign := ""
+		u64 := uint64(i64)
+		if i64 < 0 {
+			sign = "-"
+			u64 = uint64(-i64)
+		}
+		return sign + numberPrefixes[system] + strconv.FormatUint(u64, numberBases[system]), nil
+	}
+	return fmt.Sprintf("%v", f64), nil
+}
+
+// detectOutputSystem finds the type of the first number, or a function that always outputs a specific system
+func detectOutputSystem(nodes []Node) NumberSystem {
+	for i, node := range nodes {
+		// The -1 the parser adds for -(1 + 2), after a ( that isn't in the input, doesn't decide the output
+		negation := i > 0 && nodes[i-1].Type() == NodeLParen && nodes[i-1].Position() < 0
+		if node.Type() == NodeNumber && !negation {
+			num, _ := node.(NumberNode)
+			return num.System
+		}
+
+		if node.Type() == NodeFunction {
+			fn, ok := functions[node.String()]
+			if ok && fn.fixedSystem {
+				return fn.system
+			}
+
+			if closing := findClosing(nodes[i+1:]); ok && fn.detectSystem != nil && closing != -1 {
+				if system, ok := fn.detectSystem(nodes[i+2 : i+1+closing]); ok {
+					return system
+				}
+			}
+		}
 	}
+
+	return Dec
+}
+
+// Result is the full outcome of ExecResult
+type Result struct {
+	// Output is the formatted result, as given by Exec
+	Output string
+	// Decimal is the result as a plain decimal without the unit or display formatting, e.g. 255 for hex(255).
+	// Fractional parts dropped from Output are kept.
+	Decimal string
+	// Warnings are non-fatal issues noticed while executing, e.g. lost precision
+	Warnings []string
+	// value is the calculated number before any conversion or display formatting
+	value NumberNode
+}
+
+// checkAllowed checks that the nodes only use the functions and commands in AllowedCommands when it's set
+func (zs *ZappacState) checkAllowed(nodes []Node) error {
+	if zs.AllowedCommands == nil {
+		return nil
+	}
+
+	for _, node := range nodes {
+		name := commandName(node)
+		if name == "" {
+			continue
+		}
+
+		allowed := false
+		for _, allowedName := range zs.AllowedCommands {
+			if name == allowedName {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return fmt.Errorf("%s is not permitted here", name)
+		}
+	}
+
+	return nil
+}
+
+// Eval parses and executes the input, updating the variables
+func (zs *ZappacState) Eval(input string) (string, error) {
+	nodes, err := Parse(input)
+	if err != nil {
+		// Parsing always ends with where it stopped, at the start of the offending token
+		zs.setLastError(err, nodes[len(nodes)-1].Position())
+		return "", err
+	}
+
+	return zs.Exec(nodes, true)
+}
+
+// LastError gives the error from the most recent Eval or Exec as a *PositionedError, or nil if it succeeded
+func (zs *ZappacState) LastError() error {
+	if zs.lastError == nil {
+		return nil
+	}
+	return zs.lastError
+}
+
+// setLastError remembers err for LastError, clearing it when err is nil
+func (zs *ZappacState) setLastError(err error, pos Pos) {
+	if err == nil {
+		zs.lastError = nil
+		return
+	}
+	zs.lastError = &PositionedError{Err: err, Pos: pos}
 }
 
 // Exec executes logic from parsed nodes
 func (zs *ZappacState) Exec(nodes []Node, updateVariables bool) (string, error) {
+	result, err := zs.ExecResult(nodes, updateVariables)
+	return result.Output, err
+}
+
+// ExecResult executes logic from parsed nodes, giving any warnings along with the output
+func (zs *ZappacState) ExecResult(nodes []Node, updateVariables bool) (result Result, err error) {
+	defer func() {
+		zs.setLastError(err, -1)
+	}()
+
+	// Nodes can be built by hand, so never let unexpected input crash the caller
+	defer func() {
+		if r := recover(); r != nil {
+			result = Result{}
+			err = fmt.Errorf("%w: %v", ErrorInternalExec, r)
+		}
+	}()
+
+	return zs.execResult(nodes, updateVariables)
+}
+
+func (zs *ZappacState) execResult(nodes []Node, updateVariables bool) (Result, error) {
+	zs.warnings = nil
+
 	// Is there anything to do?
 	if len(nodes) == 0 {
 		// TODO: Execute previous calculation again
-		return "", nil
+		return Result{}, nil
+	}
+
+	if err := zs.checkAllowed(nodes); err != nil {
+		return Result{}, err
+	}
+
+	if len(nodes) > 1 && nodes[0].Type() == NodeAssign && nodes[1].Type() == NodeAssign {
+		return zs.execParallelAssign(nodes, updateVariables)
+	}
+
+	if IsNodeType(nodes[0], OperatorNodes) {
+		// Continue from the previous result, e.g. + 5
+		if zs.previousResult.Value == "" {
+			return Result{}, fmt.Errorf("no previous result to use with %s at pos %d", nodes[0].String(), nodes[0].Position())
+		}
+		nodes = append([]Node{zs.previousResult}, nodes...)
 	}
 
 	firstType := nodes[0].Type()
 	targetVariable := ""
+	keepSystem := false
 
 	// Fallback to decimal output, default to type of first number
-	outputSystem := Dec
-	firstNumber := findNext(nodes, []NodeType{NodeNumber})
-	if firstNumber != -1 {
-		num := nodes[firstNumber].(NumberNode)
-		outputSystem = num.System
-	}
+	outputSystem := detectOutputSystem(nodes)
 
 	if firstType == NodeSetOutput {
 		setOutput, _ := nodes[0].(SetOutputNode)
@@ -306,57 +1188,480 @@ func (zs *ZappacState) Exec(nodes []Node, updateVariables bool) (string, error)
 			targetVariable = assign.Target
 		}
 		nodes = nodes[1:]
+
+		if nodes[0].Type() == NodeSetOutput {
+			// $foo = hex(255) remembers to show $foo in hex
+			setOutput, _ := nodes[0].(SetOutputNode)
+			outputSystem = setOutput.Output
+			keepSystem = true
+			nodes = nodes[1:]
+		}
 	} else if firstType == NodeClear {
 		if updateVariables {
 			zs.clear()
-			return "Cleared state", nil
+			return Result{Output: "Cleared state"}, nil
 		}
-		return "", nil
+		return Result{}, nil
+	} else if firstType == NodeWhere {
+		return Result{Output: zs.StorageDir()}, nil
+	} else if firstType == NodeHelp {
+		return Result{Output: helpText()}, nil
+	} else if firstType == NodeInspect {
+		return Result{Output: zs.inspectText()}, nil
 	} else if firstType == NodeSave {
 		operation, _ := nodes[0].(DiskOperationNode)
 		if updateVariables {
 			msg := zs.save(operation.Profile)
-			return msg, nil
+			return Result{Output: msg}, nil
 		}
-		return "", nil
+		return Result{}, nil
 	} else if firstType == NodeLoad {
 		operation, _ := nodes[0].(DiskOperationNode)
 		if updateVariables {
 			msg := zs.load(operation.Profile)
-			return msg, nil
+			return Result{Output: msg}, nil
+		}
+		return Result{}, nil
+	}
+
+	if trimmed := TrimTerminators(nodes); len(trimmed) == 1 && trimmed[0].Type() == NodeVariable && firstType != NodeSetOutput {
+		// A variable assigned through a conversion is shown in that number system, and keeps it when copied
+		if variable, err := zs.readValue(trimmed[0]); err == nil && variable.KeepSystem {
+			outputSystem = variable.System
+			keepSystem = true
+		}
+	}
+
+	if !updateVariables && findNext(nodes, []NodeType{NodeInlineAssign}) != -1 {
+		// Let inline assignments work without touching the real variables
+		variables, lastAssigned := zs.Variables, zs.lastAssigned
+		defer func() {
+			zs.Variables, zs.lastAssigned = variables, lastAssigned
+		}()
+
+		zs.Variables = map[string]NumberNode{}
+		for name, value := range variables {
+			zs.Variables[name] = value
 		}
-		return "", nil
 	}
 
-	result, err := zs.pemdas(nodes)
+	if zs.WarnLeadingZeroOctal {
+		zs.warnOctalLiterals(nodes)
+	}
+
+	value, err := zs.pemdas(nodes)
+	result := value.Value
+	decimal := ""
 	if err == nil {
+		if decimal, err = decimalValue(value); err != nil {
+			return Result{}, err
+		}
+
+		if _, ok := value.toBigInt(); keepSystem && outputSystem != Dec && !ok {
+			// The variable would be stored without the fractional part, changing its value
+			return Result{}, fmt.Errorf("can't keep %s in %s(), it would drop the fractional part", value.Value, strings.ToLower(outputSystem.String()))
+		}
+
 		detectedSystem := parseNumberSystem(result)
 		// fmt.Printf(".. %s vs %s\n", outputSystem, detectedSystem)
 		if outputSystem != detectedSystem {
 			// fmt.Printf(".. converting %s (%s -> %s)\n", result, detectedSystem, outputSystem)
 			num := newNumber(-1, result, detectedSystem)
-			f64, err := num.toFloat64()
+			converted, err := convertNumber(num, outputSystem)
 			if err != nil {
 				log.Panicf("Got %v trying to parse %s", err, result)
 			}
 
-			if outputSystem == Hex {
-				result = "0x" + strconv.FormatInt(int64(f64), 16)
-			} else if outputSystem == Oct {
-				result = "0" + strconv.FormatInt(int64(f64), 8)
-			} else if outputSystem == Bin {
-				result = "b" + strconv.FormatInt(int64(f64), 2)
-			} else {
-				result = fmt.Sprintf("%v", f64)
+			if _, ok := num.toBigInt(); !ok && outputSystem != Dec {
+				zs.warn("fractional part of %s dropped for %s output", result, outputSystem)
 			}
+			result = converted
 		}
 
+		previous, overwritten := zs.Variables[targetVariable]
 		if targetVariable != "" {
-			zs.Variables[targetVariable] = newNumber(-1, result, parseNumberSystem(result))
+			variable := newNumber(-1, result, parseNumberSystem(result))
+			variable.Unit = value.Unit
+			variable.KeepSystem = keepSystem
+			zs.Variables[targetVariable] = variable
+			zs.lastAssigned = targetVariable
+		}
+
+		if updateVariables {
+			zs.previousResult = newNumber(-1, result, parseNumberSystem(result))
+			zs.previousResult.Unit = value.Unit
+		}
+
+		if i, ok := value.toBigInt(); ok && value.Base != 0 && outputSystem == Dec {
+			result = formatBase(i, value.Base) + value.Unit
+		} else {
+			result = zs.formatDisplay(result) + value.Unit
+		}
+		if zs.ShowPreviousValue && targetVariable != "" && overwritten {
+			result = fmt.Sprintf("%s: %s -> %s", targetVariable, zs.formatDisplay(previous.Value)+previous.Unit, result)
+		}
+	}
+
+	return Result{Output: result, Decimal: decimal, Warnings: zs.warnings, value: value}, err
+}
+
+// execParallelAssign executes an assignment to multiple variables like $a, $b = 1, 2. All the values are calculated
+// before any of the variables change, so $a, $b = $b, $a swaps them.
+func (zs *ZappacState) execParallelAssign(nodes []Node, updateVariables bool) (Result, error) {
+	targets := []Node{}
+	for len(nodes) > 0 && nodes[0].Type() == NodeAssign {
+		targets = append(targets, nodes[0])
+		nodes = nodes[1:]
+	}
+
+	values := splitArgs(TrimTerminators(nodes))
+
+	if len(values) != len(targets) {
+		return Result{}, fmt.Errorf("can't assign %d values to %d variables at pos %d", len(values), len(targets), targets[0].Position())
+	}
+
+	variables, lastAssigned := zs.Variables, zs.lastAssigned
+	assigned := map[string]NumberNode{}
+	outputs := []string{}
+	decimals := []string{}
+	warnings := []string{}
+
+	for i, value := range values {
+		// Every value sees the variables as they were before the assignment
+		zs.Variables = make(map[string]NumberNode, len(variables))
+		for name, variable := range variables {
+			zs.Variables[name] = variable
+		}
+		zs.lastAssigned = lastAssigned
+
+		assignment := append(append([]Node{targets[i]}, value...), newEOF(-1))
+		result, err := zs.execResult(assignment, updateVariables)
+		if err != nil {
+			zs.Variables, zs.lastAssigned = variables, lastAssigned
+			return Result{}, err
+		}
+
+		target := targets[i].(AssignNode).Target
+		assigned[target] = zs.Variables[target]
+		outputs = append(outputs, result.Output)
+		decimals = append(decimals, result.Decimal)
+		warnings = append(warnings, result.Warnings...)
+	}
+
+	zs.Variables = variables
+	if updateVariables {
+		for name, variable := range assigned {
+			zs.Variables[name] = variable
+		}
+	}
+
+	zs.warnings = warnings
+	return Result{Output: strings.Join(outputs, ", "), Decimal: strings.Join(decimals, ", "), Warnings: warnings}, nil
+}
+
+// decimalValue gives the number as a plain decimal, e.g. 255 for 0xff and 0.5 for the fraction 1/2
+func decimalValue(num NumberNode) (string, error) {
+	if num.System == Dec && !strings.Contains(num.Value, "/") {
+		return expandExponent(num.Value), nil
+	}
+
+	if num.System != Dec {
+		// Integers in other systems can be larger than int64, e.g. from float_bits()
+		literal := num.Value
+		if num.System == Bin {
+			literal = binaryLiteral(literal)
+		}
+		i, ok := new(big.Int).SetString(literal, 0)
+		if !ok {
+			return "", fmt.Errorf("invalid number %s", num.Value)
+		}
+		return i.String(), nil
+	}
+
+	f64, err := num.toFloat64()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(f64, 'f', -1, 64), nil
+}
+
+// ExecAll executes each line of the input as its own calculation, updating the variables, and gives the results of
+// the lines with something to calculate. Blank lines and lines with only comments are skipped. Execution stops at the
+// first error.
+func (zs *ZappacState) ExecAll(input string) ([]string, error) {
+	results := []string{}
+	for i, line := range strings.Split(input, "\n") {
+		nodes, err := Parse(line)
+		if err != nil {
+			return results, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		if len(nodes) == 1 && nodes[0].Type() == NodeEOF {
+			continue
+		}
+
+		result, err := zs.Exec(nodes, true)
+		if err != nil {
+			return results, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// EvalAllBases evaluates the input without updating variables, and formats the result in all number systems.
+// Results that are not integers are only given in decimal.
+func (zs *ZappacState) EvalAllBases(input string) (map[NumberSystem]string, error) {
+	nodes, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := zs.ExecResult(nodes, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// The calculated value rather than the output, which can have units and display formatting
+	if result.value.Value == "" {
+		return nil, fmt.Errorf("%s has no value to format", input)
+	}
+
+	return allBases(result.value)
+}
+
+// allBases converts the number to all number systems, or only decimal when it's not an integer
+func allBases(num NumberNode) (map[NumberSystem]string, error) {
+	i, ok := num.toBigInt()
+	if !ok {
+		decimal, err := convertNumber(num, Dec)
+		if err != nil {
+			return nil, err
+		}
+		return map[NumberSystem]string{Dec: decimal}, nil
+	}
+
+	results := map[NumberSystem]string{}
+	for _, system := range []NumberSystem{Dec, Hex, Oct, Bin} {
+		results[system] = formatInteger(i, system)
+	}
+
+	return results, nil
+}
+
+// EvalTimeout parses and executes the input, giving up with context.DeadlineExceeded if it takes longer than d.
+// The evaluation runs on a copy of the state, so one that timed out never changes the variables later, and it's
+// stopped after the timeout instead of running on in the background.
+func (zs *ZappacState) EvalTimeout(input string, d time.Duration) (string, error) {
+	type evalResult struct {
+		output string
+		err    error
+	}
+
+	copied := zs.clone()
+	stop := make(chan struct{})
+	copied.cancel = stop
+
+	done := make(chan evalResult, 1)
+	go func() {
+		output, err := copied.Eval(input)
+		done <- evalResult{output, err}
+	}()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		zs.Variables, zs.lastAssigned = copied.Variables, copied.lastAssigned
+		zs.previousResult, zs.lastError = copied.previousResult, copied.lastError
+		return result.output, result.err
+	case <-timer.C:
+		// Stop the evaluation, its result would be thrown away anyway
+		close(stop)
+		return "", context.DeadlineExceeded
+	}
+}
+
+// EvalBatch evaluates independent inputs concurrently, each with its own empty state, and gives the results and
+// errors in the same order as the inputs. At most GOMAXPROCS inputs are evaluated at a time.
+func EvalBatch(inputs []string) ([]string, []error) {
+	results := make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, input string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			nodes, err := Parse(input)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			zs := &ZappacState{
+				Variables: map[string]NumberNode{},
+				OnSave:    func() {},
+			}
+			results[i], errs[i] = zs.Exec(nodes, true)
+		}(i, input)
+	}
+	wg.Wait()
+
+	return results, errs
+}
+
+// ExportEnv renders the variables as NAME=value lines with decimal values, e.g. for eval in a shell.
+// Names are uppercased and characters not valid in environment variable names are replaced with _.
+func (zs *ZappacState) ExportEnv() string {
+	names := make([]string, 0, len(zs.Variables))
+	for name := range zs.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		value, err := convertNumber(zs.Variables[name], Dec)
+		if err != nil {
+			continue
+		}
+
+		sb.WriteString(envName(name))
+		sb.WriteString("=")
+		sb.WriteString(value)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// ListVariables gives the variables sorted by name as $name = value lines, with the names padded to the same width,
+// e.g. "$mask  = 0xff" and "$width = 100px"
+func (zs *ZappacState) ListVariables() []string {
+	names := make([]string, 0, len(zs.Variables))
+	width := 0
+	for name := range zs.Variables {
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(names)
+
+	variables := make([]string, len(names))
+	for i, name := range names {
+		variables[i] = fmt.Sprintf("%-*s = %s", width, name, zs.Variables[name].String())
+	}
+	return variables
+}
+
+// inspectText lists the variables and the settings affecting the results for inspect()
+func (zs *ZappacState) inspectText() string {
+	variables := zs.ListVariables()
+
+	var sb strings.Builder
+	sb.WriteString("Variables:\n")
+	if len(variables) == 0 {
+		sb.WriteString("  none\n")
+	}
+	for _, variable := range variables {
+		sb.WriteString("  " + variable + "\n")
+	}
+
+	settings := [][2]string{
+		{"bit width", strconv.Itoa(zs.bitWidth())},
+		{"fixed decimals", strconv.Itoa(zs.FixedDecimals)},
+		{"notation", zs.Notation.String()},
+		{"output case", zs.OutputCase.String()},
+		{"fraction mode", strconv.FormatBool(zs.FractionMode)},
+		{"group bit digits", strconv.FormatBool(zs.GroupBitDigits)},
+		{"min hex digits", strconv.Itoa(zs.MinHexDigits)},
+		{"min bin digits", strconv.Itoa(zs.MinBinDigits)},
+		{"max result digits", strconv.Itoa(zs.MaxResultDigits)},
+		{"equality epsilon", strconv.FormatFloat(zs.EqualityEpsilon, 'g', -1, 64)},
+	}
+
+	sb.WriteString("Settings:\n")
+	for _, setting := range settings {
+		sb.WriteString(fmt.Sprintf("  %-17s %s\n", setting[0], setting[1]))
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// ImportEnv sets variables from the environment variables starting with prefix, e.g. with the prefix ZAPPAC_VAR_
+// ZAPPAC_VAR_WIDTH=100 sets $width = 100. All valid variables are set even when some can't be read, the error is
+// for the first one that couldn't.
+func (zs *ZappacState) ImportEnv(prefix string) error {
+	environ := os.Environ()
+	sort.Strings(environ)
+
+	var firstErr error
+	for _, env := range environ {
+		key, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		name := "$" + strings.ToLower(strings.TrimPrefix(key, prefix))
+		variable, err := envVariable(name, value)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("can't read %s: %w", key, err)
+			}
+			continue
 		}
+
+		zs.Variables[name] = variable
 	}
 
-	return result, err
+	return firstErr
+}
+
+// envVariable reads the value of an environment variable as a variable called name
+func envVariable(name, value string) (NumberNode, error) {
+	nodes, err := Parse(name)
+	if err != nil || len(nodes) != 2 || nodes[0].Type() != NodeVariable {
+		return emptyNumber, fmt.Errorf("%s is not a valid variable name", name)
+	}
+
+	// Variables can keep units, e.g. 10px
+	p := NewParser()
+	p.Units = true
+	nodes, err = p.Parse(strings.TrimSpace(value))
+	if err != nil || len(nodes) != 2 || nodes[0].Type() != NodeNumber {
+		return emptyNumber, fmt.Errorf("%q is not a number", value)
+	}
+
+	number, _ := nodes[0].(NumberNode)
+	number.Pos = -1
+	return number, nil
+}
+
+// envName converts a variable name like $foo to a valid environment variable name like FOO
+func envName(name string) string {
+	name = strings.ToUpper(strings.TrimPrefix(name, "$"))
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	return name
 }
 
 // NewZappacState initializes a new ZappacState instance and loads existing state
@@ -371,13 +1676,48 @@ func NewZappacState(name string) *ZappacState {
 	return zs
 }
 
-func init() {
-	base := "."
+// NewZappacStateFromEnv initializes a new ZappacState instance, loads existing state, and sets variables from the
+// environment variables starting with prefix like ImportEnv does. The state is given even with an error, with all the
+// valid variables set.
+func NewZappacStateFromEnv(name, prefix string) (*ZappacState, error) {
+	zs := NewZappacState(name)
+	err := zs.ImportEnv(prefix)
+	return zs, err
+}
+
+// NewZappacStateFromReader initializes a new ZappacState instance with the state read from r, in the format
+// saved to profiles
+func NewZappacStateFromReader(r io.Reader) (*ZappacState, error) {
+	zs := &ZappacState{
+		Variables: map[string]NumberNode{},
+		OnSave:    func() {},
+	}
+
+	if err := zs.read(r); err != nil {
+		return nil, err
+	}
+
+	return zs, nil
+}
+
+// StorageDir gives the directory profiles are saved to and loaded from
+func (zs *ZappacState) StorageDir() string {
+	return StoragePath
+}
+
+// DefaultStoragePath gives the platform default base path of Zappac state
+func DefaultStoragePath() string {
 	if runtime.GOOS == "windows" {
-		base = path.Join(os.Getenv("APPDATA"), "zappac")
-	} else {
-		base = path.Join(os.Getenv("HOME"), ".config", "zappac")
+		return path.Join(os.Getenv("APPDATA"), "zappac")
 	}
+	return path.Join(os.Getenv("HOME"), ".config", "zappac")
+}
 
-	StoragePath = base
+// ResetStoragePath restores StoragePath to the platform default
+func ResetStoragePath() {
+	StoragePath = DefaultStoragePath()
+}
+
+func init() {
+	ResetStoragePath()
 }
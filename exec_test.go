@@ -1,10 +1,16 @@
 package zappaclang
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 type execTestCase struct {
@@ -19,7 +25,7 @@ var execTests = []execTestCase{
 	{"$bar = 0xbada55", "0xbada55"},
 	{"$bar - $foo", "12245527"},
 	{"save(foobar)", "Saved foobar"},
-	{"load(foobar)", "Loaded foobar"},
+	{"load(foobar)", "Loaded foobar (no changes)"},
 
 	// Testing that it doesn't mangle things
 	{"-1", "-1"},
@@ -29,6 +35,14 @@ var execTests = []execTestCase{
 	{"1243871635897613587671", "1243871635897613587671"},
 	{"-12438716358976137671", "-12438716358976137671"},
 
+	// Scientific notation
+	{"1e3", "1e3"},
+	{"1e3 + 1", "1001"},
+	{"2.5E-3 * 2", "0.005"},
+	{"dec(0xe)", "14"},
+	{"0xef + 1", "0xf0"},
+	{"0xe + 1e1", "0x18"},
+
 	// Basic arithmetic tests
 	{"0.1 + 0.2", "0.3"}, // Precision is off
 	{"0.1 + -0.2", "-0.1"},
@@ -44,11 +58,24 @@ var execTests = []execTestCase{
 	{"1 - 2", "-1"},
 	{"-1 - 2", "-3"},
 	{"-1 - -2", "1"},
-	{"1 * 0", "0"}, // Unexpected end of input
+	{"1 * 0", "0"},
 	{"100 * 10", "1000"},
 	{"100 * 1.234", "123.4"},
 	{"100 * 0.00123", "0.123"},
 	{"10 ** 2", "100"},
+	{"2 ** (1 + 2)", "8"},
+	{"4 ** (1 / 2) + 1", "3"},
+	{"2 ** abs(-3)", "8"},
+	{"-(1+2)", "-3"},
+	{"-(1+2) == -3", "1"},
+	{"-(-(3))", "3"},
+	{"2 * -(1+1)", "-4"},
+	{"2 ** -(1+1)", "0.25"},
+	{"1 - -(2)", "3"},
+	{"-abs(-2)", "-2"},
+	{"-|-2| + 1", "-1"},
+	{"-(0xff)", "-0xff"},
+	{"(-1)", "-1"},
 	{"(1+2)*((3-4)*5)", "-15"},
 	{"5%2", "1"},
 	{"6%2", "0"},
@@ -73,12 +100,172 @@ var execTests = []execTestCase{
 	{"hex(255)", "0xff"},
 	{"oct(8)", "010"},
 
+	// Functions
+	{"float_bits(1.0)", "0x3ff0000000000000"},
+	{"float_bits(0.0)", "0x0"},
+	{"float_bits(-2.5)", "0xc004000000000000"},
+	{"$bits = float_bits(-1.0)", "0xbff0000000000000"},
+	{"$bits // 2", "6915277227827396608"},
+	{"$bits == 0xbff0000000000000", "0x1"},
+	{"$bits + 1", "13830554455654793000"},
+	{"0xffffffffffffffff // 0x10", "0xfffffffffffffff"},
+	{"sign(-5)", "-1"},
+	{"sign(-0.0000000000000000000001)", "-1"},
+	{"sign(0)", "0"},
+	{"sign(-0)", "0"},
+	{"sign(12.5)", "1"},
+	{"sign(3 - 10)", "-1"},
+	{"popcount(0xff)", "8"},
+	{"popcount(b1011)", "3"},
+	{"popcount(0)", "0"},
+	{"popcount(-1)", "64"},
+	{"popcount(1.5)", "popcount() requires an integer, got 1.5"},
+	{"clz(1)", "63"},
+	{"clz(0xff)", "56"},
+	{"clz(0)", "64"},
+	{"clz(0.5)", "clz() requires an integer, got 0.5"},
+	{"defined($foo)", "1"},
+	{"defined($undefined)", "0"},
+	{"defined($undefined) + 2", "2"},
+	{"defined(1)", "defined() requires a single variable, e.g. defined($foo)"},
+	{"modpow(2, 10, 100)", "24"},
+	{"modpow(3, 1000000, 1000000007)", "64935414"},
+	{"3 ** 1000000 % 1000000007", "3 ** 1000000: result is infinite"},
+	{"modpow(1 + 1, (5 * 2), 100) + 1", "25"},
+	{"modpow(-2, 3, 5)", "2"},
+	{"modpow(2.5, 2, 5)", "modpow() requires an integer, got 2.5"},
+	{"modpow(2, -1, 5)", "modpow() requires a non-negative exponent, got -1"},
+	{"modpow(2, 10, 0)", "modpow() requires a positive modulus, got 0"},
+	{"modpow(2, 10)", "modpow() takes 3 arguments, got 2"},
+	{"pctchange(100, 150)", "50"},
+	{"pctchange(200, 150)", "-25"},
+	{"pctchange(0x10, 0x14)", "25"},
+	{"pctchange(-50, -25)", "-50"},
+	{"pctchange(0, 10)", "pctchange() requires a non-zero old value"},
+	{"pctchange(10)", "pctchange() takes 2 arguments, got 1"},
+	{"logn(8, 2)", "3"},
+	{"logn(100, 10)", "2"},
+	{"logn(0x100, 0x10)", "2"},
+	{"logn(0.5, 2)", "-1"},
+	{"logn(1000, 10)", "3"},
+	{"logn(125, 5)", "3"},
+	{"logn(0.001, 10)", "-3"},
+	{"logn(10, 100)", "0.5"},
+	{"logn(1001, 10) > 3", "1"},
+	{"logn(2 ** 10, 2) + 1", "11"},
+	{"logn(0, 2)", "logn() requires a positive value, got 0"},
+	{"logn(-8, 2)", "logn() requires a positive value, got -8"},
+	{"logn(8, 1)", "logn() requires a positive base other than 1, got 1"},
+	{"logn(8, 0)", "logn() requires a positive base other than 1, got 0"},
+	{"logn(8)", "logn() takes 2 arguments, got 1"},
+	{"ceildiv(7, 2)", "4"},
+	{"ceildiv(8, 2)", "4"},
+	{"ceildiv(-7, 2)", "-3"},
+	{"ceildiv(7, -2)", "-3"},
+	{"ceildiv(-7, -2)", "4"},
+	{"ceildiv(1.5, 1)", "2"},
+	{"ceildiv(0xff, 0x10)", "0x10"},
+	{"ceildiv(12438716358976137671, 10)", "1243871635897613768"},
+	{"ceildiv(7, 2) * 2", "8"},
+	{"ceildiv(7, 0)", "division by zero"},
+	{"ceildiv(7)", "ceildiv() takes 2 arguments, got 1"},
+	{"isprime(2)", "1"},
+	{"isprime(3)", "1"},
+	{"isprime(97)", "1"},
+	{"isprime(0)", "0"},
+	{"isprime(1)", "0"},
+	{"isprime(4)", "0"},
+	{"isprime(91)", "0"},
+	{"isprime(0x61)", "1"},
+	{"isprime(170141183460469231731687303715884105727)", "1"},
+	{"isprime(170141183460469231731687303715884105729)", "0"},
+	{"isprime(7.5)", "isprime() requires an integer, got 7.5"},
+	{"isprime(-7)", "isprime() requires a non-negative integer, got -7"},
+	{"nextprime(10) == 11", "1"},
+	{"nextprime(0)", "2"},
+	{"nextprime(2)", "3"},
+	{"nextprime(11)", "13"},
+	{"nextprime(0x10)", "0x11"},
+	{"nextprime(1000000)", "1000003"},
+	{"nextprime(18446744073709551615)", "18446744073709551629"},
+	{"isprime(nextprime(123456789))", "1"},
+	{"nextprime(" + strings.Repeat("9", 300) + ") > 0", "1"},
+	{"nextprime(1" + strings.Repeat("0", 400) + ")", "result too large"},
+	{"nextprime(2.5)", "nextprime() requires an integer, got 2.5"},
+	{"nextprime(-10)", "nextprime() requires a non-negative integer, got -10"},
+	{"isqrt(16) == 4", "1"},
+	{"isqrt(17) == 4", "1"},
+	{"isqrt(0)", "0"},
+	{"isqrt(1)", "1"},
+	{"isqrt(24)", "4"},
+	{"isqrt(0x100)", "0x10"},
+	{"isqrt(152415787532388367501905199875019052100)", "12345678901234567890"},
+	{"isqrt(152415787532388367501905199875019052099)", "12345678901234567889"},
+	{"isqrt(16.5)", "isqrt() requires an integer, got 16.5"},
+	{"isqrt(-16)", "isqrt() requires a non-negative integer, got -16"},
+	{`convert("ff", 16, 10)`, "255"},
+	{`convert("FF", 16, 10) + 1`, "256"},
+	{`convert("10", 10, 2)`, "b1010"},
+	{`convert("255", 10, 16)`, "0xff"},
+	{`convert("17", 10, 8)`, "021"},
+	{`convert("zz", 36, 10)`, "1295"},
+	{`convert("-101", 2, 10)`, "-5"},
+	{`convert("ffffffffffffffff", 16, 10)`, "18446744073709551615"},
+	{`convert("1ffffffffffffffff", 16, 2)`, "b1" + strings.Repeat("1", 64)},
+	{`convert("fg", 16, 10)`, "convert() requires valid digits in base 16, got fg"},
+	{`convert("", 16, 10)`, "convert() requires valid digits in base 16"},
+	{`convert("ff", 37, 10)`, "convert() requires bases from 2 to 36, got 37"},
+	{`convert("255", 10, 36)`, "36#73"},
+	{`convert("zz", 36, 36)`, "36#zz"},
+	{`convert("-10", 10, 3)`, "-3#101"},
+	{`convert("zz", 36, 36) + 1`, "1296"},
+	{`$z = convert("ff", 16, 36)`, "36#73"},
+	{`$z`, "255"},
+	{`convert(255, 10, 16)`, "convert() requires a string as the first argument"},
+	{`convert("ff", 16)`, "convert() takes 3 arguments, got 2"},
+	{`convert("ff")`, "convert() takes 3 arguments, got 1"},
+	{`sign("ff")`, `"ff" is a string, not a number`},
+	{`"ff" + 1`, `unexpected "ff" at pos 0, strings can only be function arguments`},
+	{`modpow(2, "ff", 3)`, `"ff" is a string, not a number`},
+	{`convert("ff" + 1, 16, 10)`, "unexpected + at pos 13, operators should follow numbers, variables, or closing parenthesis"},
+	{"pi", "3.141592653589793"},
+	{"e", "2.718281828459045"},
+	{"phi", "1.618033988749895"},
+	{"phi ** 2 - phi", "1"},
+	{"sqrt2", "1.4142135623730951"},
+	{"sqrt1_2", "0.7071067811865476"},
+	{"sqrt1_2 * 2 == sqrt2", "1"},
+	{"-phi", "-1.618033988749895"},
+	{"2 * pi", "6.283185307179586"},
+	{"sqrt3", "unexpected sqrt3 at pos 0"},
+	{"2 - -pi", "5.141592653589793"},
+	{"(e)", "2.718281828459045"},
+	{"2 e", "unexpected e at pos 2, constants should follow operators, (, or ="},
+	{"pi pi", "unexpected pi at pos 3, constants should follow operators, (, or ="},
+	{"2e", "unexpected e at pos 1, units can't be named like constants, use * to multiply with e"},
+	{"1e + 1", "unexpected e at pos 1, units can't be named like constants, use * to multiply with e"},
+	{"$e = e", "2.718281828459045"},
+	{"€100 + €20", "120"},
+	{"£1.50 * 2 - €0.5", "2.5"},
+	{"-€5", "-5"},
+	{"€", "Unexpected € at pos 0"},
+	{"sign(1, 2)", "sign() takes 1 argument, got 2"},
+	{"abs(1, 2)", "unexpected , at pos 5, commas can only separate function arguments"},
+	{"1, 2", "unexpected , at pos 1, commas can only separate function arguments"},
+	{"sign((1, 2))", "unexpected , at pos 7, commas can only separate function arguments"},
+
 	// Errors shouldn't crash but give a decent message
 	{"error", "unexpected error at pos 0"},
-	{"abs()", "unexpected ) at pos 4, should be following numbers, variables, or other )s"},
+	{"abs()", "expression has no value, empty parenthesis at pos 3"},
 	{"$fo", "unknown variable $fo"},
 	{"+", "unexpected + at pos 0"},
 	{"-", "unexpected - at pos 0"},
+	{"1 / (2 - 2)", "division by zero"},
+	{"1 // (2 - 2)", "division by zero"},
+	{"1 % (2 - 2)", "division by zero"},
+	{"(2 - 2) ** -1", "0 ** -1: result is infinite"},
+	{"10 ** 400", "10 ** 400: result is infinite"},
+	{"-1 ** 0.5", "-1 ** 0.5: result is not a number"},
 
 	// Non-errors
 	{"", ""},
@@ -111,7 +298,15 @@ func TestExec(t *testing.T) {
 	//}
 
 	zs := NewZappacState("")
-	for _, execTest := range runTests {
+	runExecTests(t, zs, runTests)
+}
+
+func runExecTests(t *testing.T, zs *ZappacState, tests []execTestCase) {
+	runExecTestsWithParser(t, zs, NewParser(), tests)
+}
+
+func runExecTestsWithParser(t *testing.T, zs *ZappacState, p *Parser, tests []execTestCase) {
+	for _, execTest := range tests {
 		func() {
 			// Comment out to find full trace of panics
 
@@ -122,7 +317,7 @@ func TestExec(t *testing.T) {
 			}()
 
 			start := time.Now()
-			nodes, err := Parse(execTest.Input)
+			nodes, err := p.Parse(execTest.Input)
 			elapsed := time.Since(start)
 
 			if err != nil {
@@ -157,3 +352,1634 @@ func TestExec(t *testing.T) {
 		}()
 	}
 }
+
+func TestExecFixedDecimals(t *testing.T) {
+	zs := NewZappacState("")
+	zs.FixedDecimals = 2
+
+	runExecTests(t, zs, []execTestCase{
+		{"1.5 + 1.5", "3.00"},
+		{"1.50 + 1.50", "3.00"},
+		{"10 / 4", "2.50"},
+		{"1 / 3", "0.33"},
+		{"$foo = 2 / 3", "0.66"},
+		{"$foo * 3", "2.00"},
+		{"hex(255)", "0xff"},
+		{"1e3", "1000.00"},
+		{"-2.5E-3", "-0.00"},
+		{"1.25e1", "12.50"},
+	})
+}
+
+func TestExecBitWidth(t *testing.T) {
+	zs := NewZappacState("")
+	zs.BitWidth = 32
+
+	runExecTests(t, zs, []execTestCase{
+		{"clz(1)", "31"},
+		{"clz(0xffff)", "16"},
+		{"popcount(-1)", "32"},
+	})
+}
+
+func TestExecMaxResultDigits(t *testing.T) {
+	zs := NewZappacState("")
+	zs.MaxResultDigits = 50
+
+	runExecTests(t, zs, []execTestCase{
+		{"10 ** 20", "100000000000000000000"},
+		{"-2 ** 21", "-2097152"},
+		{"10 ** 100", "result too large"},
+		{"9 ** 9 ** 9 ** 9", "result too large"},
+		{"(10 ** 30) * (10 ** 30)", "result too large"},
+	})
+
+	zs.FractionMode = true
+	zs.MaxResultDigits = 10
+	runExecTests(t, zs, []execTestCase{
+		{"10 ** 9", "1000000000"},
+		{"-(10 ** 9) * 9", "-9000000000"},
+		{"1/3 ** 20", "1/3486784401"},
+		{"10 ** 10", "result too large"},
+		{"10 ** 1000", "result too large"},
+		{"(10 ** 9) * 100", "result too large"},
+		{"2 ** 9223372036854775807", "result too large"},
+	})
+
+	zs.MaxResultDigits = 0
+	runExecTests(t, zs, []execTestCase{
+		{"10 ** 100", "1" + strings.Repeat("0", 100)},
+		{"3 ** 100000000", "result too large"},
+		{"(1/3) ** 100000000", "result too large"},
+	})
+}
+
+func TestExecFractionMode(t *testing.T) {
+	zs := NewZappacState("")
+	zs.FractionMode = true
+
+	runExecTests(t, zs, []execTestCase{
+		{"1/3", "1/3"},
+		{"2/4", "1/2"},
+		{"1/3 + 1/3", "2/3"},
+		{"1/3 * 3", "1"},
+		{"0.5 + 1/4", "3/4"},
+		{"(2/3) ** 2", "4/9"},
+		{"(2/3) ** -1", "3/2"},
+		{"dec(0x10 / 6)", "8/3"},
+		{"$third = 1/3", "1/3"},
+		{"$third * 6", "2"},
+		{"1/(2-2)", "division by zero"},
+		{"4 ** (1/2)", "2"},
+		{"(1/4) ** 0.5", "0.5"},
+	})
+
+	zs.FixedDecimals = 2
+	runExecTests(t, zs, []execTestCase{
+		{"1 / 3", "1/3"},
+		{"2 / 4", "1/2"},
+		{"6 / 3", "2.00"},
+		{"4 ** (1/2)", "2.00"},
+	})
+}
+
+func TestEvalAllBases(t *testing.T) {
+	zs := NewZappacState("")
+	zs.Variables["$foo"] = newNumber(-1, "0xf0", Hex)
+
+	tests := []struct {
+		input    string
+		expected map[NumberSystem]string
+	}{
+		{"255", map[NumberSystem]string{Dec: "255", Hex: "0xff", Oct: "0377", Bin: "b11111111"}},
+		{"$foo + 0xf", map[NumberSystem]string{Dec: "255", Hex: "0xff", Oct: "0377", Bin: "b11111111"}},
+		{"b101 * 2", map[NumberSystem]string{Dec: "10", Hex: "0xa", Oct: "012", Bin: "b1010"}},
+		{"1 / 4", map[NumberSystem]string{Dec: "0.25"}},
+		{"$width", map[NumberSystem]string{Dec: "5", Hex: "0x5", Oct: "05", Bin: "b101"}},
+		{"hex(0xffffffff)", map[NumberSystem]string{Dec: "4294967295", Hex: "0xffffffff", Oct: "037777777777", Bin: "b11111111111111111111111111111111"}},
+		{"0x7fffffffffffffff", map[NumberSystem]string{Dec: "9223372036854775807", Hex: "0x7fffffffffffffff", Oct: "0777777777777777777777", Bin: "b" + strings.Repeat("1", 63)}},
+	}
+
+	// Display settings only change the output, never the values in all bases
+	settings := []func(zs *ZappacState){
+		func(zs *ZappacState) {},
+		func(zs *ZappacState) { zs.ResultFormatter = func(float64, NumberSystem) string { return "$255.00" } },
+		func(zs *ZappacState) { zs.GroupBitDigits = true },
+		func(zs *ZappacState) { zs.FixedDecimals = 2 },
+		func(zs *ZappacState) { zs.Notation = Scientific },
+	}
+
+	for _, setting := range settings {
+		zs := NewZappacState("")
+		zs.Variables["$foo"] = newNumber(-1, "0xf0", Hex)
+		zs.Variables["$width"] = NumberNode{NodeType: NodeNumber, Pos: -1, Value: "5", System: Dec, Unit: "px"}
+		setting(zs)
+
+		for _, test := range tests {
+			results, err := zs.EvalAllBases(test.input)
+			if err != nil {
+				t.Errorf("%s: got\n\t%v\nexpected\n\t%v", test.input, err, test.expected)
+				continue
+			}
+
+			if fmt.Sprint(results) != fmt.Sprint(test.expected) {
+				t.Errorf("%s: got\n\t%v\nexpected\n\t%v", test.input, results, test.expected)
+			}
+		}
+	}
+
+	if _, err := zs.EvalAllBases("$undefined"); err == nil {
+		t.Errorf("$undefined: expected an error")
+	}
+}
+
+func TestExecInlineAssignment(t *testing.T) {
+	zs := NewZappacState("")
+	p := NewParser()
+	p.InlineAssignment = true
+
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"($x := 5) + $x", "10"},
+		{"$x", "5"},
+		{"1 + ($y := 2 * 3) * $y", "37"},
+		{"$z := 0xff", "0xff"},
+		{"$z + 1", "256"},
+		{"$a = ($b := 2) ** $b", "4"},
+		{"$b", "2"},
+		{"5 := 1", "unexpected := at pos 2, inline assignment must follow a variable name. Ex: ($foo := 1)"},
+	})
+
+	runExecTests(t, zs, []execTestCase{
+		{"($x := 5) + $x", "unexpected := at pos 4, inline assignment is not enabled"},
+	})
+
+	// Inline assignments should not leak when not updating variables
+	nodes, err := p.Parse("($preview := 1) + $preview")
+	if err != nil {
+		t.Errorf("%+v", err)
+		return
+	}
+
+	result, err := zs.Exec(nodes, false)
+	if err != nil || result != "2" {
+		t.Errorf("preview: got\n\t%s, %v\nexpected\n\t2", result, err)
+	}
+
+	if _, ok := zs.Variables["$preview"]; ok {
+		t.Errorf("preview: $preview should not have been assigned")
+	}
+}
+
+func TestExecComparisons(t *testing.T) {
+	zs := NewZappacState("")
+
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"1 < 2", "1"},
+		{"2 < 1", "0"},
+		{"2 <= 2", "1"},
+		{"3 > 2 + 2", "0"},
+		{"0xff == 255", "0x1"},
+		{"0.1 + 0.2 != 0.3", "1"}, // Float precision is not hidden
+		{"$x = 5", "5"},
+		{"1 < $x < 10", "1"},
+		{"1 < $x * 3 < 10", "0"},
+		{"10 > $x >= 5 > 4", "1"},
+		{"(1 < $x) < 10", "1"},
+		{"(1 < 0) + 2", "2"},
+		{"1px < 2em", "conflicting units px and em"},
+	})
+}
+
+func TestExecNegativeConversions(t *testing.T) {
+	zs := NewZappacState("")
+
+	runExecTests(t, zs, []execTestCase{
+		{"hex(-7)", "-0x7"},
+		{"bin(-3)", "-b11"},
+		{"oct(-8)", "-010"},
+		{"hex(-7 + b01)", "-0x6"},
+		{"$n = -0x7 + 0", "-0x7"},
+		{"$n - 1", "-8"},
+		{"dec(-0x7)", "-7"},
+		{"-0x7 + 0", "-0x7"},
+	})
+}
+
+func TestExecTreatUnknownVarsAsZero(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"$undefined + 5", "unknown variable $undefined"},
+	})
+
+	zs.TreatUnknownVarsAsZero = true
+	runExecTests(t, zs, []execTestCase{
+		{"$undefined + 5", "5"},
+		{"$undefined + 5 == 5", "1"},
+		{"$foo = $undefined * 2", "0"},
+		{"defined($undefined)", "0"},
+	})
+}
+
+func TestExecLeadingOperator(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"10", "10"},
+		{"+ 5", "unexpected + at pos 0"},
+	})
+
+	zs.clear()
+	p := NewParser()
+	p.LeadingOperator = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"+ 5", "no previous result to use with + at pos 0"},
+		{"10", "10"},
+		{"+ 5", "15"},
+		{"* 2", "30"},
+		{"- 5", "-5"},
+		{"- $foo", "unknown variable $foo"},
+		{"$foo = 3", "3"},
+		{"- $foo", "0"},
+		{"hex(255)", "0xff"},
+		{"+ 1", "0x100"},
+		{"+", "unexpected end of input after operator '+' at pos 0"},
+		{"clear()", "Cleared state"},
+		{"+ 5", "no previous result to use with + at pos 0"},
+	})
+}
+
+func TestExecAbsBars(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	p := NewParser()
+	p.OperatorAliases = DefaultOperatorAliases
+
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"|-5|", "5"},
+		{"|−2| + 1", "3"},
+		{"$foo = -3", "-3"},
+		{"|$foo| * 2", "6"},
+		{"||$foo| - 5|", "2"},
+		{"|(1 - 5)| * 2", "8"},
+		{"3 | |−2| == 3 | abs(−2)", "1"},
+		{"|−2| | 3 == abs(−2) | 3", "1"},
+		{"|1 | 2|", "unexpected 2 at pos 5, | at pos 3 closes the |...| group, use parenthesis for bitwise or inside it, e.g. |(1 | 2)|"},
+		{"|1 | $foo|", "unexpected $foo at pos 5, | at pos 3 closes the |...| group, use parenthesis for bitwise or inside it, e.g. |(1 | 2)|"},
+		{"(1) 2", "unexpected 2 at pos 4, looks like a negative number that doesn't make sense here"},
+		{"(|1)", "unexpected ) at pos 3, | at pos 1 must be closed first"},
+		{"|1", "unexpected end of input, | at pos 0 is not closed"},
+	})
+}
+
+func TestExecEqualityEpsilon(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"0.1 + 0.2 == 0.3", "0"},
+		{"0.1 + 0.2 != 0.3", "1"},
+	})
+
+	zs.EqualityEpsilon = 1e-9
+	runExecTests(t, zs, []execTestCase{
+		{"0.1 + 0.2 == 0.3", "1"},
+		{"0.1 + 0.2 != 0.3", "0"},
+		{"1 == 1.001", "0"},
+		{"0.1 + 0.2 > 0.3", "1"},
+	})
+}
+
+func TestExecWhere(t *testing.T) {
+	dir := t.TempDir()
+	StoragePath = dir
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	if zs.StorageDir() != dir {
+		t.Errorf("got\n\t%s\nexpected\n\t%s", zs.StorageDir(), dir)
+	}
+
+	runExecTests(t, zs, []execTestCase{
+		{"where()", dir},
+		{"where(1)", "unexpected where at pos 0, when used the input should be only: where()"},
+		{"1 + where()", "unexpected where at pos 4, when used the input should be only: where()"},
+	})
+}
+
+func TestListVariables(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	if variables := zs.ListVariables(); len(variables) != 0 {
+		t.Errorf("expected no variables, got %v", variables)
+	}
+
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$width = 100px", "100px"},
+		{"$mask = 0xff", "0xff"},
+		{"$a = -b101", "-b101"},
+	})
+
+	expected := []string{"$a     = -b101", "$mask  = 0xff", "$width = 100px"}
+	if variables := zs.ListVariables(); fmt.Sprint(variables) != fmt.Sprint(expected) {
+		t.Errorf("got\n\t%q\nexpected\n\t%q", variables, expected)
+	}
+}
+
+func TestExecInspect(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	output, err := zs.Eval("inspect()")
+	if err != nil {
+		t.Fatalf("inspect() failed: %s", err)
+	}
+	if !strings.Contains(output, "Variables:\n  none\n") {
+		t.Errorf("expected no variables in inspect() output\n%s", output)
+	}
+
+	zs.BitWidth = 32
+	zs.FixedDecimals = 2
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$width = 100px", "100.00px"},
+		{"$mask = hex(255)", "0xff"},
+	})
+
+	output, err = zs.Eval("inspect()")
+	if err != nil {
+		t.Fatalf("inspect() failed: %s", err)
+	}
+
+	for _, expected := range []string{"$mask  = 0xff", "$width = 100px", "bit width         32", "fixed decimals    2", "notation          Standard"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in inspect() output\n%s", expected, output)
+		}
+	}
+
+	runExecTests(t, zs, []execTestCase{
+		{"inspect(1)", "unexpected inspect at pos 0, when used the input should be only: inspect()"},
+		{"1 + inspect()", "unexpected inspect at pos 4, when used the input should be only: inspect()"},
+	})
+}
+
+func TestExecHelp(t *testing.T) {
+	zs := NewZappacState("")
+
+	output, err := zs.Eval("help()")
+	if err != nil {
+		t.Fatalf("help() failed: %s", err)
+	}
+
+	for _, expected := range []string{"+   addition", "//  floor division", "sign(a)", "modpow(a, b, c)", "clear()", "help()"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in help() output\n%s", expected, output)
+		}
+	}
+
+	runExecTests(t, zs, []execTestCase{
+		{"help(1)", "unexpected help at pos 0, when used the input should be only: help()"},
+		{"1 + help()", "unexpected help at pos 4, when used the input should be only: help()"},
+	})
+}
+
+func TestExecUnits(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	// Units are off by default
+	runExecTests(t, zs, []execTestCase{
+		{"100px + 20px", "unexpected px at pos 3, units are not enabled, use * to multiply"},
+		{"$width = 10px", "unexpected px at pos 11, units are not enabled, use * to multiply"},
+		{"10px * 2px", "unexpected px at pos 2, units are not enabled, use * to multiply"},
+	})
+
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"100px + 20px", "120px"},
+		{"100px * 2", "200px"},
+		{"(1.5em + 2) * 2", "7em"},
+		{"-10px - 5", "-15px"},
+		{"$width = 10px", "10px"},
+		{"$width + 5", "15px"},
+		{"100px + 20em", "conflicting units px and em"},
+		{"$width * 2em", "conflicting units px and em"},
+	})
+}
+
+func TestExecMagnitudeSuffixes(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"10k + 5", "unexpected k at pos 2, units are not enabled, use * to multiply"},
+	})
+
+	p := NewParser()
+	p.MagnitudeSuffixes = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"10k + 5", "10005"},
+		{"1.5k", "1500"},
+		{"2m - 1g", "-998000000"},
+		{"-2k", "-2000"},
+		{"1ki", "1024"},
+		{"1.5mi", "1572864"},
+		{"1e3k", "1000000"},
+		{"0.0001k", "0.1"},
+		{"0xabc", "0xabc"},
+		{"0755k", "unexpected k at pos 4, magnitude suffixes only work with decimal numbers, got 0755"},
+		{"-017k", "unexpected k at pos 4, magnitude suffixes only work with decimal numbers, got -017"},
+		{"b11k", "unexpected k at pos 3"},
+		{"0k", "0"},
+		{"100px", "unexpected px at pos 3, units are not enabled, use * to multiply"},
+	})
+
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"10k + 5", "10005"},
+		{"100px", "100px"},
+	})
+}
+
+func TestExecShowPreviousValue(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	zs.ShowPreviousValue = true
+
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$foo = 5", "5"},
+		{"$foo = $foo + 7", "$foo: 5 -> 12"},
+		{"$foo * 2", "24"},
+		{"$width = 10px", "10px"},
+		{"$width = 0x20", "$width: 10px -> 0x20"},
+	})
+
+	zs.ShowPreviousValue = false
+	runExecTests(t, zs, []execTestCase{
+		{"$foo = 1", "1"},
+	})
+}
+
+func TestExecLastAssigned(t *testing.T) {
+	zs := NewZappacState("")
+	p := NewParser()
+	p.InlineAssignment = true
+
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$$ + 5", "$$ used before any variable was assigned"},
+		{"$foo = 10", "10"},
+		{"$$ + 5", "15"},
+		{"$bar = 0xff", "0xff"},
+		{"$$", "255"},
+		{"($baz := 2) * $$", "4"},
+		{"$$ = 1", "can't assign to $$, it always references the last assigned variable"},
+		{"($$ := 1)", "can't assign to $$, it always references the last assigned variable"},
+	})
+}
+
+func TestExecOperatorAliases(t *testing.T) {
+	zs := NewZappacState("")
+	p := NewParser()
+	p.OperatorAliases = DefaultOperatorAliases
+
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"2 × 3", "6"},
+		{"6 ÷ 2", "3"},
+		{"−1 − 2", "-3"},
+		{"(1 + 2) × −2", "-6"},
+	})
+
+	p.OperatorAliases = map[string]string{"x": "*", "mod": "%"}
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"2 x 3", "6"},
+		{"7 mod 4", "3"},
+	})
+
+	runExecTests(t, zs, []execTestCase{
+		{"2 × 3", "Unexpected × at pos 2"},
+	})
+}
+
+func TestExecWarnings(t *testing.T) {
+	zs := NewZappacState("")
+
+	tests := []struct {
+		input    string
+		output   string
+		warnings []string
+	}{
+		{"10 / 2", "5", nil},
+		{"10 / 3", "3.3333333333333335", []string{"10 / 3 has a remainder, the result is not exact"}},
+		{"10 // 3", "3", nil},
+		{"2 ** 60", "1152921504606847000", []string{"precision lost, 2 ** 60 is larger than 2^53"}},
+		{"1 + 9007199254740993", "9007199254740992", []string{"precision lost, 9007199254740993 is larger than 2^53"}},
+		{"9007199254740993 - 1", "9007199254740991", []string{"precision lost, 9007199254740993 is larger than 2^53"}},
+		{"9007199254740992 - 1", "9007199254740991", nil},
+		{"hex(3 / 2)", "0x1", []string{"3 / 2 has a remainder, the result is not exact", "fractional part of 1.5 dropped for Hex output"}},
+		{"hex(4 / 2)", "0x2", nil},
+	}
+
+	for _, test := range tests {
+		nodes, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		result, err := zs.ExecResult(nodes, true)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		if result.Output != test.output || fmt.Sprint(result.Warnings) != fmt.Sprint(test.warnings) {
+			t.Errorf("%s: got\n\t%s %q\nexpected\n\t%s %q", test.input, result.Output, result.Warnings, test.output, test.warnings)
+		}
+	}
+}
+
+func TestExecWarnLeadingZeroOctal(t *testing.T) {
+	zs := NewZappacState("")
+	zs.WarnLeadingZeroOctal = true
+
+	tests := []struct {
+		input    string
+		output   string
+		warnings []string
+	}{
+		{"0755", "0755", []string{"interpreted 0755 as octal (493)"}},
+		{"dec(0755)", "493", []string{"interpreted 0755 as octal (493)"}},
+		{"010 + 010", "020", []string{"interpreted 010 as octal (8)", "interpreted 010 as octal (8)"}},
+		{"0x755", "0x755", nil},
+		{"0.5 + 0", "0.5", nil},
+	}
+
+	for _, test := range tests {
+		nodes, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		result, err := zs.ExecResult(nodes, true)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		if result.Output != test.output || fmt.Sprint(result.Warnings) != fmt.Sprint(test.warnings) {
+			t.Errorf("%s: got\n\t%s %q\nexpected\n\t%s %q", test.input, result.Output, result.Warnings, test.output, test.warnings)
+		}
+	}
+
+	zs.WarnLeadingZeroOctal = false
+	nodes, _ := Parse("0755")
+	if result, _ := zs.ExecResult(nodes, true); len(result.Warnings) != 0 {
+		t.Errorf("expected no warnings without WarnLeadingZeroOctal, got %q", result.Warnings)
+	}
+}
+
+func TestExecResultFormatter(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	zs.ResultFormatter = func(value float64, system NumberSystem) string {
+		return fmt.Sprintf("[%v %s]", value, system)
+	}
+
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"1 + 2", "[3 Dec]"},
+		{"hex(255)", "[255 Hex]"},
+		{"$foo = 10 / 4", "[2.5 Dec]"},
+		{"$foo * 2", "[5 Dec]"},
+		{"5px", "[5 Dec]px"},
+	})
+
+	zs.ResultFormatter = nil
+	runExecTests(t, zs, []execTestCase{
+		{"1 + 2", "3"},
+	})
+}
+
+func TestExecStrictBitwise(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	zs.Variables["$mask"] = newNumber(-1, "0xf0", Hex)
+
+	tests := []struct {
+		input    string
+		warnings []string
+	}{
+		{"0xff & 10", []string{"mixing hex and decimal in bitwise op 0xff & 10"}},
+		{"b101 | 0755", []string{"mixing binary and octal in bitwise op b101 | 0755"}},
+		{"$mask ^ 3", []string{"mixing hex and decimal in bitwise op 0xf0 ^ 3"}},
+		{"0xff & 0x0f", nil},
+		{"$mask & 0x0f", nil},
+		{"0xff + 10", nil},
+		{"0xff << 2", nil},
+		{"(1 + 2) & 0xff", nil},
+	}
+
+	for _, strict := range []bool{false, true} {
+		zs.StrictBitwise = strict
+
+		for _, test := range tests {
+			nodes, err := Parse(test.input)
+			if err != nil {
+				t.Errorf("%s: got\n\t%v", test.input, err)
+				continue
+			}
+
+			result, err := zs.ExecResult(nodes, true)
+			if err != nil {
+				t.Errorf("%s: got\n\t%v", test.input, err)
+				continue
+			}
+
+			expected := test.warnings
+			if !strict {
+				expected = nil
+			}
+			if fmt.Sprint(result.Warnings) != fmt.Sprint(expected) {
+				t.Errorf("%s: got\n\t%q\nexpected\n\t%q", test.input, result.Warnings, expected)
+			}
+		}
+	}
+}
+
+func TestExecGroupBitDigits(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	zs.GroupBitDigits = true
+
+	runExecTests(t, zs, []execTestCase{
+		{"0xffffffff", "0xffff_ffff"},
+		{"hex(2 ** 40 - 1)", "0xff_ffff_ffff"},
+		{"0xfff", "0xfff"},
+		{"bin(170)", "b1010_1010"},
+		{"bin(-42)", "-b10_1010"},
+		{"$foo = b11111111", "b1111_1111"},
+		{"$foo + 1", "256"},
+		{"hex($foo + 1)", "0x100"},
+		{"oct(4095)", "07777"},
+		{"12345678", "12345678"},
+	})
+}
+
+func TestExecGroupSeparator(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	zs.GroupBitDigits = true
+
+	for _, separator := range []rune{',', '.', ' '} {
+		zs.GroupSeparator = separator
+		s := string(separator)
+		runExecTests(t, zs, []execTestCase{
+			{"0xffffffff", "0xffff" + s + "ffff"},
+			{"bin(-42)", "-b10" + s + "1010"},
+			{"0xfff", "0xfff"},
+			{"12345678", "12345678"},
+		})
+	}
+}
+
+func TestExecOutputCase(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"0xff", "0xff"},
+		{"0XAB + 1", "0xac"},
+	})
+
+	zs.OutputCase = Uppercase
+	runExecTests(t, zs, []execTestCase{
+		{"0xff", "0XFF"},
+		{"hex(48879)", "0XBEEF"},
+		{"-0xff", "-0XFF"},
+		{"bin(5)", "B101"},
+		{"oct(15)", "017"},
+		{"1.5e3", "1.5e3"},
+		{"$foo = 0xab", "0XAB"},
+		{"$foo", "171"},
+	})
+
+	zs.GroupBitDigits = true
+	runExecTests(t, zs, []execTestCase{
+		{"0xabcdef12", "0XABCD_EF12"},
+	})
+}
+
+func TestExecAllowedCommands(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	zs.clear()
+	zs.AllowedCommands = []string{"abs", "hex"}
+
+	runExecTests(t, zs, []execTestCase{
+		{"abs(-5) + 1", "6"},
+		{"|-5|", "5"},
+		{"hex(255)", "0xff"},
+		{"$foo = 2 ** 8", "256"},
+		{"save(foo)", "save is not permitted here"},
+		{"load(foo)", "load is not permitted here"},
+		{"clear()", "clear is not permitted here"},
+		{"sign(-2)", "sign is not permitted here"},
+		{"1 + abs(sign(-2))", "sign is not permitted here"},
+		{"bin(5)", "bin is not permitted here"},
+		{"$foo", "256"},
+	})
+
+	zs.AllowedCommands = []string{}
+	runExecTests(t, zs, []execTestCase{
+		{"1 + 2", "3"},
+		{"abs(-5)", "abs is not permitted here"},
+	})
+
+	zs.AllowedCommands = nil
+	runExecTests(t, zs, []execTestCase{
+		{"sign(-2)", "-1"},
+	})
+}
+
+func TestExecParallelAssignment(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"$a, $b = 1, 2", "1, 2"},
+		{"$a", "1"},
+		{"$b", "2"},
+		{"$a, $b = $b, $a", "2, 1"},
+		{"$a * 10 + $b", "21"},
+		{"$x, $y, $z = 0xff, modpow(2, 10, 1000), -(1 + 2)", "0xff, 24, -3"},
+		{"$x + $y + $z", "276"},
+		{"$$", "-3"},
+		{"$a, $b = 1, 2, 3", "can't assign 3 values to 2 variables at pos 0"},
+		{"$a, $b, $c = 1, 2", "can't assign 2 values to 3 variables at pos 0"},
+		{"$a, $b = 5", "can't assign 1 values to 2 variables at pos 0"},
+		{"$a, $a = 1, 2", "can't assign to $a twice at pos 4"},
+		{"$a, $b", "unexpected , at pos 2, the variables of a parallel assignment must be followed by =. Ex: $a, $b = 1, 2"},
+		{"$a, $b =", "expression has no value, nothing is assigned to $b at pos 4"},
+		{"$a = 1, 2", "unexpected , at pos 6, commas can only separate function arguments"},
+		{"$a, $b = 3, $nope", "unknown variable $nope"},
+		{"$a", "2"},
+	})
+}
+
+func TestExecAssignConversion(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"$foo = hex(0xff + 1)", "0x100"},
+		{"$foo", "0x100"},
+		{"$foo + 1", "257"},
+		{"dec($foo)", "256"},
+		{"$bits = bin(5)", "b101"},
+		{"$copy = $bits", "b101"},
+		{"$copy", "b101"},
+		{"$plain = 0x10", "0x10"},
+		{"$plain", "16"},
+		{"save(conversions)", "Saved conversions"},
+		{"$foo = 3", "3"},
+		{"$foo", "3"},
+		{"load(conversions)", "Loaded conversions (1 variable changed)"},
+		{"$foo", "0x100"},
+		{"$f = bin(2.5)", "can't keep 2.5 in bin(), it would drop the fractional part"},
+		{"$f", "unknown variable $f"},
+		{"$h = hex(3 / 4)", "can't keep 0.75 in hex(), it would drop the fractional part"},
+		{"$h = hex(3 / 4 * 4)", "0x3"},
+		{"$h * 4", "12"},
+		{"$d = dec(2.5)", "2.5"},
+		{"$d + 0", "2.5"},
+		{"$foo = 1 + hex(2)", "unexpected hex at pos 11, setting output type must be the first thing you do"},
+		{"$foo += hex(2)", "unexpected hex at pos 8, setting output type must be the first thing you do"},
+	})
+}
+
+func TestExecMinDigits(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	zs.MinHexDigits = 2
+	zs.MinBinDigits = 8
+
+	runExecTests(t, zs, []execTestCase{
+		{"hex(15)", "0x0f"},
+		{"hex(255)", "0xff"},
+		{"hex(4095)", "0xfff"},
+		{"hex(-1)", "-0x01"},
+		{"bin(5)", "b00000101"},
+		{"bin(-5)", "-b00000101"},
+		{"bin(511)", "b111111111"},
+		{"$foo = 0xa", "0x0a"},
+		{"$foo + 1", "11"},
+		{"oct(8)", "010"},
+		{"15", "15"},
+	})
+
+	// Padding happens before grouping
+	zs.GroupBitDigits = true
+	zs.MinHexDigits = 8
+	runExecTests(t, zs, []execTestCase{
+		{"hex(255)", "0x0000_00ff"},
+		{"bin(5)", "b0000_0101"},
+	})
+}
+
+func TestExecConstantProfileNames(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"$foo = pi", "3.141592653589793"},
+		{"save(e)", "Saved e"},
+		{"save(pi)", "Saved pi"},
+		{"$foo = 1", "1"},
+		{"load(e)", "Loaded e (1 variable changed)"},
+		{"$foo", "3.141592653589793"},
+	})
+}
+
+func TestExecResultDecimal(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	zs.FixedDecimals = 2
+
+	tests := []struct {
+		input   string
+		output  string
+		decimal string
+	}{
+		{"hex(255)", "0xff", "255"},
+		{"0x10 + 1", "0x11", "17"},
+		{"-b101", "-b101", "-5"},
+		{"hex(3 / 2)", "0x1", "1.5"},
+		{"10px / 4", "2.50px", "2.5"},
+		{"1e3", "1000.00", "1000"},
+		{"2.5e-3", "0.00", "0.0025"},
+		{"-1.5E2", "-150.00", "-150"},
+		{"float_bits(-2.5)", "0xc004000000000000", "13836183955189006336"},
+		{"save(foo)", "Saved foo", ""},
+	}
+
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	p := NewParser()
+	p.Units = true
+	for _, test := range tests {
+		nodes, err := p.Parse(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		result, err := zs.ExecResult(nodes, true)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		if result.Output != test.output || result.Decimal != test.decimal {
+			t.Errorf("%s: got\n\t%s %s\nexpected\n\t%s %s", test.input, result.Output, result.Decimal, test.output, test.decimal)
+		}
+	}
+
+	zs.FixedDecimals = 0
+	zs.FractionMode = true
+	nodes, _ := Parse("1 / 4")
+	if result, _ := zs.ExecResult(nodes, true); result.Output != "1/4" || result.Decimal != "0.25" {
+		t.Errorf("1 / 4: got\n\t%s %s\nexpected\n\t1/4 0.25", result.Output, result.Decimal)
+	}
+}
+
+func TestExecScientific(t *testing.T) {
+	zs := NewZappacState("")
+	zs.Notation = Scientific
+
+	runExecTests(t, zs, []execTestCase{
+		{"1234567", "1.234567e+06"},
+		{"0.00012 * 2", "2.4e-04"},
+		{"-1500 * 1", "-1.5e+03"},
+		{"hex(255)", "0xff"},
+		{"$big = 10 ** 9", "1e+09"},
+	})
+
+	zs.FixedDecimals = 2
+	runExecTests(t, zs, []execTestCase{
+		{"1234567", "1.23e+06"},
+	})
+
+	zs.Notation = Standard
+	zs.FixedDecimals = 0
+	runExecTests(t, zs, []execTestCase{
+		{"1234567", "1234567"},
+		{"$big", "1000000000"},
+	})
+}
+
+func TestExecEngineering(t *testing.T) {
+	zs := NewZappacState("")
+	zs.Notation = Engineering
+
+	runExecTests(t, zs, []execTestCase{
+		{"12345", "12.345e3"},
+		{"1234567", "1.234567e6"},
+		{"123456789", "123.456789e6"},
+		{"100 * 10", "1e3"},
+		{"1 * 100", "100e0"},
+		{"0.5 * 1", "500e-3"},
+		{"0.00012 * 2", "240e-6"},
+		{"-1500 * 1", "-1.5e3"},
+		{"2 - 2", "0e0"},
+		{"hex(255)", "0xff"},
+	})
+
+	zs.FixedDecimals = 2
+	runExecTests(t, zs, []execTestCase{
+		{"12345", "12.35e3"},
+		{"999.994 * 1", "999.99e0"},
+		{"999.996 * 1", "1.00e3"},
+		{"-999999.999 * 1", "-1.00e6"},
+	})
+
+	zs.FixedDecimals = 1
+	runExecTests(t, zs, []execTestCase{
+		{"999.96 * 1", "1.0e3"},
+		{"0.99996 * 1", "1.0e0"},
+	})
+}
+
+func TestExportEnv(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"$width = 0xff", "0xff"},
+		{"$half_size = 1 / 2", "0.5"},
+	})
+
+	// Loaded state could contain names the lexer would never produce
+	zs.Variables["$1st-value"] = newNumber(-1, "b11", Bin)
+
+	expected := "_1ST_VALUE=3\nHALF_SIZE=0.5\nWIDTH=255\n"
+	if result := zs.ExportEnv(); result != expected {
+		t.Errorf("got\n\t%q\nexpected\n\t%q", result, expected)
+	}
+}
+
+func TestNewZappacStateFromEnv(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	t.Setenv("ZAPPAC_TEST_WIDTH", "100")
+	t.Setenv("ZAPPAC_TEST_MASK", "0xff")
+	t.Setenv("ZAPPAC_TEST_OFFSET", " -5px ")
+	t.Setenv("OTHER_WIDTH", "1")
+
+	zs, err := NewZappacStateFromEnv("", "ZAPPAC_TEST_")
+	if err != nil {
+		t.Fatalf("got\n\t%v", err)
+	}
+
+	runExecTests(t, zs, []execTestCase{
+		{"$width * 2", "200"},
+		{"$mask + 1", "256"},
+		{"$offset * 2", "-10px"},
+		{"$other_width", "unknown variable $other_width"},
+	})
+
+	t.Setenv("ZAPPAC_TEST_BROKEN", "1 +")
+	t.Setenv("ZAPPAC_TEST_BAD-NAME", "1")
+	zs, err = NewZappacStateFromEnv("", "ZAPPAC_TEST_")
+	if err == nil || err.Error() != `can't read ZAPPAC_TEST_BAD-NAME: $bad-name is not a valid variable name` {
+		t.Errorf("got\n\t%v", err)
+	}
+	if zs == nil || zs.Variables["$width"].Value != "100" || zs.Variables["$mask"].Value != "0xff" {
+		t.Fatalf("expected the valid variables to be kept along with the error, got %+v", zs)
+	}
+	if _, ok := zs.Variables["$broken"]; ok {
+		t.Errorf("expected $broken to be skipped, got %+v", zs.Variables)
+	}
+
+	zs = NewZappacState("")
+	err = zs.ImportEnv("ZAPPAC_TEST_")
+	if err == nil || zs.Variables["$width"].Value != "100" {
+		t.Errorf("expected the valid variables to be set along with an error, got %v and %+v", err, zs.Variables)
+	}
+}
+
+func TestNewZappacStateFromReader(t *testing.T) {
+	saved := NewZappacState("")
+	saved.clear()
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, saved, p, []execTestCase{
+		{"$foo = 0xff", "0xff"},
+		{"$bar = 10px", "10px"},
+	})
+
+	contents, err := yaml.Marshal(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zs, err := NewZappacStateFromReader(strings.NewReader(string(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runExecTests(t, zs, []execTestCase{
+		{"$foo + 1", "256"},
+		{"$bar * 2", "20px"},
+	})
+
+	if _, err := NewZappacStateFromReader(strings.NewReader("variables: [")); err == nil {
+		t.Errorf("expected an error for invalid state")
+	}
+}
+
+func TestSaveIsDeterministic(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	zs.clear()
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$zeta = 1", "1"},
+		{"$alpha = 0xff", "0xff"},
+		{"$mid = 10px", "10px"},
+		{"$beta = b101", "b101"},
+		{"save(first)", "Saved first"},
+		{"save(second)", "Saved second"},
+	})
+
+	firstFile, _ := getProfileFile("first")
+	first, err := os.ReadFile(firstFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondFile, _ := getProfileFile("second")
+	second, err := os.ReadFile(secondFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(first) != string(second) {
+		t.Errorf("saves differ:\n%s\n%s", first, second)
+	}
+
+	alpha := strings.Index(string(first), "$alpha")
+	zeta := strings.Index(string(first), "$zeta")
+	if alpha == -1 || zeta == -1 || alpha > zeta {
+		t.Errorf("expected sorted variables, got\n%s", first)
+	}
+}
+
+func TestExecRecoversPanics(t *testing.T) {
+	zs := NewZappacState("")
+
+	// The parser never gives a dangling operator, but nodes can be built by hand
+	nodes := []Node{newNumber(0, "1", Dec), newOperator(1, "+")}
+	result, err := zs.Exec(nodes, true)
+	if !errors.Is(err, ErrorInternalExec) {
+		t.Errorf("expected ErrorInternalExec, got %q, %v", result, err)
+	}
+}
+
+func TestEvalTimeout(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	result, err := zs.EvalTimeout("$foo = 2 ** 10", time.Second)
+	if err != nil || result != "1024" {
+		t.Errorf("got\n\t%s, %v\nexpected\n\t1024", result, err)
+	}
+	if zs.Variables["$foo"].Value != "1024" {
+		t.Errorf("expected $foo to be assigned, got %+v", zs.Variables)
+	}
+
+	if zs.previousResult.Value != "1024" {
+		t.Errorf("expected the previous result to be kept, got %+v", zs.previousResult)
+	}
+
+	_, _ = zs.EvalTimeout("1 + * 2", time.Second)
+	var pe *PositionedError
+	if !errors.As(zs.LastError(), &pe) || pe.Pos != 4 {
+		t.Errorf("expected the error to be kept, got %v", zs.LastError())
+	}
+
+	heavy := "$foo = " + strings.Repeat("1 + ", 50000) + "1"
+	result, err = zs.EvalTimeout(heavy, time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %s, %v", result, err)
+	}
+	if zs.Variables["$foo"].Value != "1024" {
+		t.Errorf("expected $foo to be unchanged, got %+v", zs.Variables)
+	}
+}
+
+func TestEvalTimeoutStops(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	// Without stopping, this would keep calculating for minutes after the timeout
+	goroutines := runtime.NumGoroutine()
+	heavy := strings.Repeat("1 + ", 50000) + "1"
+	if _, err := zs.EvalTimeout(heavy, time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the evaluation to stop, %d goroutines are running, %d before", runtime.NumGoroutine(), goroutines)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	zs.cancel = stop
+	if _, err := zs.Eval("1 + 2"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestLastError(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	if zs.LastError() != nil {
+		t.Errorf("expected no error before evaluating anything, got %v", zs.LastError())
+	}
+
+	_, _ = zs.Eval("1 + * 2")
+	var pe *PositionedError
+	if !errors.As(zs.LastError(), &pe) {
+		t.Fatalf("expected a *PositionedError, got %v", zs.LastError())
+	}
+	if pe.Error() != "unexpected * at pos 4, operators should follow numbers, variables, or closing parenthesis" || pe.Pos != 4 {
+		t.Errorf("got\n\t%v at %d", pe, pe.Pos)
+	}
+
+	_, _ = zs.Eval("+")
+	if !errors.As(zs.LastError(), &pe) || pe.Pos != 0 {
+		t.Errorf("got\n\t%v at %d", zs.LastError(), pe.Pos)
+	}
+
+	_, _ = zs.Eval("$foo + 1")
+	if !errors.As(zs.LastError(), &pe) || pe.Error() != "unknown variable $foo" || pe.Pos != -1 {
+		t.Errorf("got\n\t%v", zs.LastError())
+	}
+
+	if result, err := zs.Eval("1 + 2"); err != nil || result != "3" {
+		t.Errorf("got\n\t%s, %v", result, err)
+	}
+	if zs.LastError() != nil {
+		t.Errorf("expected the error to be cleared, got %v", zs.LastError())
+	}
+}
+
+func TestOperationError(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+	zs.FractionMode = true
+
+	tests := []struct {
+		input    string
+		expected OperationError
+	}{
+		{"popcount(1.5)", OperationError{"popcount()", "1.5", "requires an integer"}},
+		{"modpow(2, -1, 5)", OperationError{"modpow()", "-1", "requires a non-negative exponent"}},
+		{"defined(1)", OperationError{"defined()", "", "requires a single variable, e.g. defined($foo)"}},
+		{"pctchange(0, 1)", OperationError{"pctchange()", "", "requires a non-zero old value"}},
+		{"1 ** 9223372036854775808", OperationError{"**", "9223372036854775808", "requires an exponent that fits in 64 bits in fraction mode"}},
+	}
+
+	for _, test := range tests {
+		_, err := zs.Eval(test.input)
+		var oe *OperationError
+		if !errors.As(err, &oe) {
+			t.Errorf("%s: expected an *OperationError, got %v", test.input, err)
+			continue
+		}
+		if *oe != test.expected {
+			t.Errorf("%s: got\n\t%+v\nexpected\n\t%+v", test.input, *oe, test.expected)
+		}
+	}
+
+	_, err := ToHex("1.5")
+	var oe *OperationError
+	if !errors.As(err, &oe) || err.Error() != "conversion to Hex requires an integer, got 1.5" {
+		t.Errorf("expected an *OperationError, got %v", err)
+	}
+}
+
+func TestExecAll(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	tests := []struct {
+		input    string
+		expected []string
+		err      string
+	}{
+		{"", []string{}, ""},
+		{"\n   \n\t\n", []string{}, ""},
+		{"# just a comment", []string{}, ""},
+		{"\n# comment\n  # indented comment\n\n", []string{}, ""},
+		{"$width = 100 # in px\n\n# double it\n$width * 2", []string{"100", "200"}, ""},
+		{"1 + 1\n$undefined\n2 + 2", []string{"2"}, "line 2: unknown variable $undefined"},
+		{"1 +\n2", []string{}, "line 1: unexpected end of input after operator '+' at pos 2"},
+	}
+
+	for _, test := range tests {
+		results, err := zs.ExecAll(test.input)
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+
+		if fmt.Sprintf("%q", results) != fmt.Sprintf("%q", test.expected) || errStr != test.err {
+			t.Errorf("%q: got\n\t%q %s\nexpected\n\t%q %s", test.input, results, errStr, test.expected, test.err)
+		}
+	}
+}
+
+func TestEvalBatch(t *testing.T) {
+	inputs := []string{"1 + 2", "$foo", "hex(255)", "1 / 0", "$foo = 5", "+", "2 ** 10"}
+	expected := []string{"3", "unknown variable $foo", "0xff", "division by zero", "5", "unexpected + at pos 0", "1024"}
+
+	results, errs := EvalBatch(inputs)
+	if len(results) != len(inputs) || len(errs) != len(inputs) {
+		t.Fatalf("expected %d results and errors, got %d and %d", len(inputs), len(results), len(errs))
+	}
+
+	for i, input := range inputs {
+		result := results[i]
+		if errs[i] != nil {
+			result = errs[i].Error()
+		}
+		if result != expected[i] {
+			t.Errorf("%s: got\n\t%s\nexpected\n\t%s", input, result, expected[i])
+		}
+	}
+}
+
+func TestExecUnbalancedParentheses(t *testing.T) {
+	zs := NewZappacState("")
+
+	// The parser never gives unbalanced parentheses, but nodes can be built by hand
+	inputs := [][]Node{
+		{newLParen(0), newNumber(1, "1", Dec)},
+		{newNumber(0, "2", Dec), newOperator(1, "*"), newLParen(2), newLParen(3), newNumber(4, "1", Dec), newRParen(5)},
+		{newFunction(0, "sign"), newLParen(4), newNumber(5, "1", Dec)},
+	}
+
+	for _, nodes := range inputs {
+		result, err := zs.Exec(nodes, true)
+		if !errors.Is(err, ErrorUnbalancedParentheses) {
+			t.Errorf("%+v: expected ErrorUnbalancedParentheses, got %q, %v", nodes, result, err)
+		}
+	}
+}
+
+func TestDiffProfiles(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	zs.clear()
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$same = 1", "1"},
+		{"$changed = 0xff", "0xff"},
+		{"$only_a = 10px", "10px"},
+		{"save(a)", "Saved a"},
+		{"clear()", "Cleared state"},
+		{"$same = 1", "1"},
+		{"$changed = 0xfe", "0xfe"},
+		{"$only_b = 2", "2"},
+		{"save(b)", "Saved b"},
+	})
+
+	diff, err := DiffProfiles("a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][2]string{
+		"$changed": {"0xff", "0xfe"},
+		"$only_a":  {"10px", ""},
+		"$only_b":  {"", "2"},
+	}
+	if fmt.Sprint(diff) != fmt.Sprint(expected) {
+		t.Errorf("got\n\t%v\nexpected\n\t%v", diff, expected)
+	}
+
+	if _, err := DiffProfiles("a", "missing"); err == nil {
+		t.Errorf("expected an error for a missing profile")
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$hex = 0xff", "0xff"},
+		{"$neg = -b101", "-b101"},
+		{"$width = 10px", "10px"},
+		{"save(valid)", "Saved valid"},
+	})
+
+	if err := ValidateProfile("valid"); err != nil {
+		t.Errorf("expected the profile to be valid, got %v", err)
+	}
+
+	fractions := NewZappacState("")
+	fractions.FractionMode = true
+	runExecTests(t, fractions, []execTestCase{
+		{"$third = 1 / 3", "1/3"},
+		{"$neg = -2 / 4", "-1/2"},
+		{"save(fractions)", "Saved fractions"},
+	})
+
+	if err := ValidateProfile("fractions"); err != nil {
+		t.Errorf("expected the profile with fractions to be valid, got %v", err)
+	}
+
+	corrupted := "variables:\n    $ok:\n        value: \"1\"\n    $broken:\n        value: \"12abc\"\n"
+	if err := os.WriteFile(StoragePath+"/corrupted.json", []byte(corrupted), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ValidateProfile("corrupted")
+	expected := `invalid value "12abc" for $broken in profile corrupted`
+	if err == nil || err.Error() != expected {
+		t.Errorf("got\n\t%v\nexpected\n\t%s", err, expected)
+	}
+
+	if err := os.WriteFile(StoragePath+"/zero.json", []byte("variables:\n    $zero:\n        value: \"1/0\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateProfile("zero"); err == nil || err.Error() != `invalid value "1/0" for $zero in profile zero` {
+		t.Errorf("got\n\t%v", err)
+	}
+
+	if err := ValidateProfile("missing"); err == nil {
+		t.Errorf("expected an error for a missing profile")
+	}
+
+	// Validating must not change the live state
+	if len(zs.Variables) != 3 {
+		t.Errorf("expected the state to be unchanged, got %+v", zs.Variables)
+	}
+}
+
+func TestExecProfileReferences(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	base := NewZappacState("")
+	base.clear()
+	runExecTests(t, base, []execTestCase{
+		{"$x = 5", "5"},
+		{"$width = 0xff", "0xff"},
+		{"save(base)", "Saved base"},
+	})
+
+	zs := NewZappacState("")
+	zs.clear()
+	runExecTests(t, zs, []execTestCase{
+		{"$base::x * 2", "base::x can't be read, profile references are not enabled"},
+	})
+
+	otherFile, _ := getProfileFile("other")
+	zs.ProfileReferences = true
+	runExecTests(t, zs, []execTestCase{
+		{"$base::x * 2", "10"},
+		{"$y = $base::width + 1", "256"},
+		{"$base::missing", "unknown variable $missing in profile base"},
+		{"$other::x", "can't read profile other: open " + otherFile + ": no such file or directory"},
+		{"$base::x = 1", "can't assign to $base::x, variables in other profiles are read only"},
+	})
+
+	if _, ok := zs.Variables["$x"]; ok {
+		t.Errorf("expected the profile variables to not be loaded, got %+v", zs.Variables)
+	}
+}
+
+func TestResetStoragePath(t *testing.T) {
+	StoragePath = t.TempDir()
+	ResetStoragePath()
+
+	if StoragePath != DefaultStoragePath() {
+		t.Errorf("expected StoragePath %s, got %s", DefaultStoragePath(), StoragePath)
+	}
+}
+
+func TestExecIntegerDivision(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"12438716358976137671 // 7", "1776959479853733953"},
+		{"12438716358976137671 // -7", "-1776959479853733953"},
+		{"12438716358976137671 % 1000", "671"},
+		{"18446744073709551617 % 10", "7"},
+		{"-17 // 5", "-4"},
+		{"-17 % 5", "-2"},
+		{"0xff // 0x10", "0xf"},
+		{"7.5 // 2", "3"},
+		{"100px // 7", "14px"},
+		{"1 // 0", "division by zero"},
+	})
+}
+
+func TestProfileNameRequired(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	if msg := zs.save(""); msg != "profile name required" {
+		t.Errorf("save: got\n\t%s\nexpected\n\tprofile name required", msg)
+	}
+	if msg := zs.load(""); msg != "profile name required" {
+		t.Errorf("load: got\n\t%s\nexpected\n\tprofile name required", msg)
+	}
+
+	if _, err := os.Stat(StoragePath + "/.json"); !os.IsNotExist(err) {
+		t.Errorf("expected no file for the empty profile name, got %v", err)
+	}
+
+	if _, err := DiffProfiles("", "other"); !errors.Is(err, ErrorProfileNameRequired) {
+		t.Errorf("DiffProfiles: expected ErrorProfileNameRequired, got %v", err)
+	}
+}
+
+func TestExecLoadChanges(t *testing.T) {
+	StoragePath = t.TempDir()
+	defer ResetStoragePath()
+
+	zs := NewZappacState("")
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$a = 1", "1"},
+		{"$b = 0xff", "0xff"},
+		{"$c = 10px", "10px"},
+		{"save(profile)", "Saved profile"},
+		{"load(profile)", "Loaded profile (no changes)"},
+		{"$a = 2", "2"},
+		{"load(profile)", "Loaded profile (1 variable changed)"},
+	})
+
+	empty := NewZappacState("")
+	runExecTests(t, empty, []execTestCase{
+		{"load(profile)", "Loaded profile (3 variables changed)"},
+		{"load(profile)", "Loaded profile (no changes)"},
+	})
+}
+
+func TestExecCompoundAssignment(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"$foo = 3", "3"},
+		{"$foo **= 2", "9"},
+		{"$foo", "9"},
+		{"$foo **= 1 / 2", "3"},
+		{"$foo **= 2 ** 2", "81"},
+		{"$foo -= 1 + 10", "70"},
+		{"$foo *= 2", "140"},
+		{"$foo /= 4", "35"},
+		{"$foo //= 2", "17"},
+		{"$foo %= 5", "2"},
+		{"$foo <<= 3", "16"},
+		{"$foo >>= 2", "4"},
+		{"$foo += -1", "3"},
+		{"$hex = 0xf", "0xf"},
+		{"$hex += 0x1", "0x10"},
+		{"$undefined += 1", "unknown variable $undefined"},
+		{"$foo +=", "expression has no value, nothing is assigned to $foo at pos 0"},
+		{"1 += 2", "unexpected += at pos 2, compound assignment can only follow a variable name at the very start of the line. Ex: $foo += 1"},
+		{"$foo + $foo += 2", "unexpected += at pos 12, compound assignment can only follow a variable name at the very start of the line. Ex: $foo += 1"},
+		{"$$ += 1", "can't assign to $$, it always references the last assigned variable"},
+	})
+}
+
+func TestExecRotate(t *testing.T) {
+	zs := NewZappacState("")
+	zs.BitWidth = 32
+
+	runExecTests(t, zs, []execTestCase{
+		{"rotl(0x80000000, 1) == 1", "0x1"},
+		{"rotl(0x80000000, 1)", "0x1"},
+		{"rotr(1, 1)", "2147483648"},
+		{"rotl(0x12345678, 8)", "0x34567812"},
+		{"rotr(0x12345678, 8)", "0x78123456"},
+		{"rotl(0x12345678, 40)", "0x34567812"},
+		{"rotl(0x12345678, -8)", "0x78123456"},
+		{"rotl(0xff, 0)", "0xff"},
+		{"rotl(-1, 3)", "4294967295"},
+		{"rotl(1.5, 1)", "rotl() requires an integer, got 1.5"},
+		{"rotr(1, 0.5)", "rotr() requires an integer, got 0.5"},
+	})
+
+	zs.BitWidth = 8
+	runExecTests(t, zs, []execTestCase{
+		{"rotl(b10000001, 1)", "b11"},
+		{"rotr(b11, 1)", "b10000001"},
+	})
+
+	zs.BitWidth = 0
+	runExecTests(t, zs, []execTestCase{
+		{"rotl(0x80000000, 1)", "0x100000000"},
+		{"rotr(1, 1)", "9223372036854775808"},
+	})
+}
+
+func TestExecPercentAssignment(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$rate = 5%", "0.05"},
+		{"$rate * 200 == 10", "1"},
+		{"$rate * 200", "10"},
+		{"$tax = 24.5%", "0.245"},
+		{"$neg = -12%", "-0.12"},
+		{"$whole = 200%", "2"},
+		{"$width = 50px%", "0.5px"},
+		{"7 % 3", "1"},
+		{"$mod = 7 % 3", "1"},
+		{"5%", "unexpected end of input after operator '%' at pos 1"},
+		{"$x = 1 + 5%", "unexpected end of input after operator '%' at pos 10"},
+		{"$hex = 0x10%", "unexpected end of input after operator '%' at pos 11"},
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	p := NewParser()
+	p.Units = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"$foo = 0xff", "0xff"},
+		{"$bar = 10px", "10px"},
+	})
+
+	snapshot := zs.Snapshot()
+
+	zs.FixedDecimals = 2
+	runExecTests(t, zs, []execTestCase{
+		{"$foo = 1", "1.00"},
+		{"$baz = 2", "2.00"},
+		{"clear()", "Cleared state"},
+	})
+
+	for i := 0; i < 2; i++ {
+		zs.RestoreSnapshot(snapshot)
+		runExecTests(t, zs, []execTestCase{
+			{"$foo", "255"},
+			{"$bar + 1", "11px"},
+			{"$$", "10px"},
+			{"$baz", "unknown variable $baz"},
+			{"$foo = 1", "1"},
+		})
+	}
+}
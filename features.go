@@ -0,0 +1,175 @@
+package zappaclang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// features are the optional grammar features supported by this build, besides the functions
+var features = []string{
+	"abs-bars",
+	"bitwise",
+	"comments",
+	"comparisons",
+	"compound-assignment",
+	"constants",
+	"currency-symbols",
+	"fractions",
+	"implicit-multiplication",
+	"inline-assignment",
+	"last-assigned",
+	"leading-operator",
+	"magnitude-suffixes",
+	"operator-aliases",
+	"parallel-assignment",
+	"profile-references",
+	"scientific",
+	"units",
+}
+
+// Features lists the grammar features this build supports, e.g. "bitwise" or "functions:abs,sign", so front-ends
+// can advertise their capabilities
+func Features() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := append([]string{}, features...)
+	return append(result, "functions:"+strings.Join(names, ","))
+}
+
+// Keywords lists the identifiers the lexer recognizes, including the functions and constants, e.g. for syntax
+// highlighting
+func Keywords() []string {
+	names := make([]string, 0, len(keywords)+len(functions)+len(constants))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	for name := range constants {
+		names = append(names, name)
+	}
+	for name := range functions {
+		if _, ok := keywords[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OperatorInfo describes an operator for help texts and autocompletion
+type OperatorInfo struct {
+	Symbol      string
+	Arity       int
+	Description string
+}
+
+// FunctionInfo describes a function for help texts and autocompletion
+type FunctionInfo struct {
+	Name        string
+	Arity       int
+	Description string
+}
+
+var operatorDescriptions = map[NodeType]string{
+	NodeAdd:    "addition",
+	NodeSub:    "subtraction",
+	NodeMult:   "multiplication",
+	NodeExp:    "exponent",
+	NodeDiv:    "division",
+	NodeFdiv:   "floor division",
+	NodeAnd:    "bitwise and",
+	NodeOr:     "bitwise or",
+	NodeXor:    "bitwise xor",
+	NodeInv:    "bitwise inversion",
+	NodeMod:    "modulus",
+	NodeLShift: "left shift",
+	NodeRShift: "right shift",
+	NodeLt:     "1 if less than, 0 if not",
+	NodeLte:    "1 if less than or equal, 0 if not",
+	NodeGt:     "1 if greater than, 0 if not",
+	NodeGte:    "1 if greater than or equal, 0 if not",
+	NodeEq:     "1 if equal, 0 if not",
+	NodeNe:     "1 if not equal, 0 if equal",
+}
+
+// builtinFunctions are the functions handled by the parser rather than the functions map
+var builtinFunctions = []FunctionInfo{
+	{"bin", 1, "show the result in binary"},
+	{"clear", 0, "remove all variables"},
+	{"dec", 1, "show the result in decimal"},
+	{"help", 0, "list the operators, functions and commands"},
+	{"hex", 1, "show the result in hexadecimal"},
+	{"inspect", 0, "list the variables and settings"},
+	{"load", 1, "load variables from a profile"},
+	{"oct", 1, "show the result in octal"},
+	{"save", 1, "save variables to a profile"},
+	{"where", 0, "show where profiles are saved"},
+}
+
+// Operators lists all the supported operators in order of their node types
+func Operators() []OperatorInfo {
+	symbols := map[NodeType]string{}
+	for symbol, typ := range operatorMap {
+		symbols[typ] = symbol
+	}
+
+	result := []OperatorInfo{}
+	for _, typ := range OperatorNodes {
+		arity := 2
+		if typ == NodeInv {
+			arity = 1
+		}
+		result = append(result, OperatorInfo{symbols[typ], arity, operatorDescriptions[typ]})
+	}
+
+	return result
+}
+
+// Functions lists all the supported functions sorted by name
+func Functions() []FunctionInfo {
+	result := append([]FunctionInfo{}, builtinFunctions...)
+	for name, fn := range functions {
+		result = append(result, FunctionInfo{name, fn.argCount(), fn.description})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// helpText lists the operators, functions and commands for help()
+func helpText() string {
+	var sb strings.Builder
+
+	sb.WriteString("Operators:\n")
+	for _, operator := range Operators() {
+		sb.WriteString(fmt.Sprintf("  %-3s %s\n", operator.Symbol, operator.Description))
+	}
+
+	list := Functions()
+	width := 0
+	signatures := make([]string, len(list))
+	for i, function := range list {
+		args := make([]string, function.Arity)
+		for j := range args {
+			args[j] = string(rune('a' + j))
+		}
+		signatures[i] = function.Name + "(" + strings.Join(args, ", ") + ")"
+		if len(signatures[i]) > width {
+			width = len(signatures[i])
+		}
+	}
+
+	sb.WriteString("Functions and commands:\n")
+	for i, function := range list {
+		sb.WriteString(fmt.Sprintf("  %-*s %s\n", width, signatures[i], function.Description))
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
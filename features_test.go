@@ -0,0 +1,91 @@
+package zappaclang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFeatures(t *testing.T) {
+	list := Features()
+
+	for _, expected := range []string{"bitwise", "fractions", "units"} {
+		found := false
+		for _, feature := range list {
+			if feature == expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected %s in %v", expected, list)
+		}
+	}
+
+	last := list[len(list)-1]
+	if !strings.HasPrefix(last, "functions:abs,") || !strings.Contains(last, ",sign") {
+		t.Errorf("expected sorted functions, got %s", last)
+	}
+
+	// Callers must not be able to change the features
+	list[0] = "changed"
+	if Features()[0] == "changed" {
+		t.Errorf("Features() returned the internal list")
+	}
+}
+
+func TestOperators(t *testing.T) {
+	operators := map[string]OperatorInfo{}
+	for _, operator := range Operators() {
+		if operator.Description == "" {
+			t.Errorf("%s has no description", operator.Symbol)
+		}
+		operators[operator.Symbol] = operator
+	}
+
+	for _, symbol := range []string{"+", "-", "*", "**", "/", "//", "%", "<<", ">>", "<", "=="} {
+		if operators[symbol].Arity != 2 {
+			t.Errorf("expected binary operator %s, got %+v", symbol, operators[symbol])
+		}
+	}
+
+	if operators["~"].Arity != 1 {
+		t.Errorf("expected unary ~, got %+v", operators["~"])
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	functions := map[string]FunctionInfo{}
+	for _, function := range Functions() {
+		if function.Description == "" {
+			t.Errorf("%s has no description", function.Name)
+		}
+		functions[function.Name] = function
+	}
+
+	for _, name := range []string{"abs", "sign", "popcount", "clz", "defined", "hex", "dec", "save", "load"} {
+		if functions[name].Arity != 1 {
+			t.Errorf("expected function %s, got %+v", name, functions[name])
+		}
+	}
+
+	if _, ok := functions["clear"]; !ok {
+		t.Errorf("expected clear in %+v", functions)
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	list := Keywords()
+
+	seen := map[string]bool{}
+	for _, keyword := range list {
+		if seen[keyword] {
+			t.Errorf("%s listed twice in %v", keyword, list)
+		}
+		seen[keyword] = true
+	}
+
+	for _, expected := range []string{"abs", "dec", "hex", "bin", "oct", "save", "load", "clear", "where", "sign", "pi", "sqrt1_2"} {
+		if !seen[expected] {
+			t.Errorf("expected %s in %v", expected, list)
+		}
+	}
+}
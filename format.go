@@ -0,0 +1,214 @@
+package zappaclang
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Notation identifies how decimal results are displayed
+type Notation int
+
+const (
+	// Standard notation, e.g. 1234567
+	Standard Notation = iota
+	// Scientific notation, e.g. 1.234567e+06
+	Scientific
+	// Engineering notation with exponents that are multiples of 3, e.g. 1.234567e6
+	Engineering
+)
+
+//go:generate stringer -type=Notation
+
+// LetterCase identifies how the letters in hex and binary results are displayed
+type LetterCase int
+
+const (
+	// Lowercase digits and prefixes, e.g. 0xff
+	Lowercase LetterCase = iota
+	// Uppercase digits and prefixes, e.g. 0XFF
+	Uppercase
+)
+
+//go:generate stringer -type=LetterCase
+
+// formatDisplay applies display-only formatting to a result, the stored values are never affected
+func (zs *ZappacState) formatDisplay(result string) string {
+	if zs.ResultFormatter != nil {
+		system := parseNumberSystem(result)
+		if f64, err := newNumber(-1, result, system).toFloat64(); err == nil {
+			return zs.ResultFormatter(f64, system)
+		}
+	}
+
+	if system := parseNumberSystem(result); system != Dec {
+		if system == Hex && zs.MinHexDigits > 0 {
+			result = padDigits(result, system, zs.MinHexDigits)
+		} else if system == Bin && zs.MinBinDigits > 0 {
+			result = padDigits(result, system, zs.MinBinDigits)
+		}
+
+		if zs.GroupBitDigits && (system == Hex || system == Bin) {
+			result = groupDigits(result, system, zs.groupSeparator())
+		}
+
+		// Numbers are always stored in lowercase, so only uppercase needs changes
+		if zs.OutputCase == Uppercase {
+			result = strings.ToUpper(result)
+		}
+		return result
+	}
+
+	if zs.Notation == Scientific || zs.Notation == Engineering {
+		f64, err := newNumber(-1, result, Dec).toFloat64()
+		if err != nil {
+			return result
+		}
+
+		if zs.Notation == Engineering {
+			return formatEngineering(f64, zs.FixedDecimals)
+		}
+
+		precision := -1
+		if zs.FixedDecimals > 0 {
+			precision = zs.FixedDecimals
+		}
+		return strconv.FormatFloat(f64, 'e', precision, 64)
+	}
+
+	// Fractions from FractionMode are exact, there are no decimals to fix
+	if zs.FixedDecimals > 0 && !strings.Contains(result, "/") {
+		result = fixDecimals(expandExponent(result), zs.FixedDecimals)
+	}
+
+	return result
+}
+
+// groupSeparator gives the configured GroupSeparator, falling back to _
+func (zs *ZappacState) groupSeparator() rune {
+	if zs.GroupSeparator == 0 {
+		return '_'
+	}
+	return zs.GroupSeparator
+}
+
+// padDigits adds leading zeros to hex and binary numbers with fewer digits than minDigits, e.g. 0xf to 0x0f
+func padDigits(number string, system NumberSystem, minDigits int) string {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign = "-"
+		number = number[1:]
+	}
+
+	prefix := numberPrefixes[system]
+	digits := number[len(prefix):]
+	if len(digits) >= minDigits {
+		return sign + number
+	}
+
+	return sign + prefix + strings.Repeat("0", minDigits-len(digits)) + digits
+}
+
+// groupDigits separates hex and binary digits in groups of 4 from the right with the separator, e.g. 0xffff_ffff
+// and b1010_1010 with _
+func groupDigits(number string, system NumberSystem, separator rune) string {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign = "-"
+		number = number[1:]
+	}
+
+	prefix := numberPrefixes[system]
+	digits := number[len(prefix):]
+
+	var sb strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%4 == 0 {
+			sb.WriteRune(separator)
+		}
+		sb.WriteRune(digit)
+	}
+
+	return sign + prefix + sb.String()
+}
+
+// formatEngineering formats the number with an exponent that is a multiple of 3, e.g. 12345 as 12.345e3.
+// When decimals is over 0 the mantissa is rounded to that many fractional digits.
+func formatEngineering(value float64, decimals int) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	// Shortest exact digits, e.g. 1.2345e+04
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(value, 'e', -1, 64), "e")
+	exp, _ := strconv.Atoi(exponent)
+
+	engExp := exp - ((exp%3)+3)%3
+	shift := exp - engExp
+
+	digits := strings.Replace(mantissa, ".", "", 1)
+	if len(digits) <= shift {
+		digits += strings.Repeat("0", shift+1-len(digits))
+	}
+
+	mantissa = digits[:shift+1]
+	if fraction := digits[shift+1:]; fraction != "" {
+		mantissa += "." + fraction
+	}
+
+	if decimals > 0 {
+		m, _ := strconv.ParseFloat(mantissa, 64)
+		mantissa = strconv.FormatFloat(m, 'f', decimals, 64)
+
+		// Rounding can carry over to the next exponent, e.g. 999.96 is 1.0e3 rather than 1000.0e0
+		if rounded, _ := strconv.ParseFloat(mantissa, 64); rounded >= 1000 {
+			mantissa = strconv.FormatFloat(rounded/1000, 'f', decimals, 64)
+			engExp += 3
+		}
+	}
+
+	return sign + mantissa + "e" + strconv.Itoa(engExp)
+}
+
+// maxExpandedExponent limits the exponents expandExponent writes out, larger ones would give huge strings
+const maxExpandedExponent = 1000
+
+// expandExponent writes a decimal number in scientific notation as a plain decimal, e.g. 1e3 as 1000 and 2.5e-3 as
+// 0.0025. Other numbers are given as they are.
+func expandExponent(number string) string {
+	mantissa, exponent, found := strings.Cut(strings.ToLower(number), "e")
+	if !found {
+		return number
+	}
+
+	exp, err := strconv.Atoi(exponent)
+	if err != nil || exp > maxExpandedExponent || exp < -maxExpandedExponent {
+		return number
+	}
+
+	r, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return number
+	}
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// The digits of the fraction and the exponent tell how many decimals are needed for the exact value
+	_, fraction, _ := strings.Cut(mantissa, ".")
+	return strings.TrimRight(r.FloatString(len(fraction)-exp), "0")
+}
+
+// fixDecimals pads or truncates the fractional part of a decimal number string to exactly n digits
+func fixDecimals(number string, n int) string {
+	integer, fraction, _ := strings.Cut(number, ".")
+	if len(fraction) > n {
+		fraction = fraction[:n]
+	} else {
+		fraction += strings.Repeat("0", n-len(fraction))
+	}
+
+	return integer + "." + fraction
+}
@@ -0,0 +1,526 @@
+package zappaclang
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+)
+
+type function struct {
+	// description is a short explanation for help texts
+	description string
+	// system is always used for the output when fixedSystem is set
+	system      NumberSystem
+	fixedSystem bool
+	// arity is the number of arguments, 0 means 1
+	arity int
+	eval  func(zs *ZappacState, arg NumberNode) (NumberNode, error)
+	// evalArgs is used instead of eval for functions with multiple arguments
+	evalArgs func(zs *ZappacState, args []NumberNode) (NumberNode, error)
+	// evalNodes is used instead of eval when the function needs its argument unevaluated
+	evalNodes func(zs *ZappacState, args []Node) (NumberNode, error)
+	// evalString is used instead of eval for functions taking a string followed by numbers, e.g. convert("ff", 16, 10)
+	evalString func(zs *ZappacState, text string, args []NumberNode) (NumberNode, error)
+	// detectSystem gives the output system from the unevaluated arguments when they decide it, e.g. for convert()
+	detectSystem func(args []Node) (NumberSystem, bool)
+}
+
+// baseSystems maps the bases to their number systems
+var baseSystems = map[int64]NumberSystem{
+	2:  Bin,
+	8:  Oct,
+	10: Dec,
+	16: Hex,
+}
+
+// maxPrimeBits limits the size of the integers for nextprime(), larger ones could take practically forever to search
+const maxPrimeBits = 1024
+
+// functions contains all the functions callable as name(...), abs() has its own node type for historical reasons
+var functions = map[string]function{
+	"abs": {
+		description: "absolute value",
+		eval: func(zs *ZappacState, arg NumberNode) (NumberNode, error) {
+			f64, err := arg.toFloat64()
+			if err != nil {
+				return emptyNumber, err
+			}
+			return newFloatNumber(math.Abs(f64)), nil
+		},
+	},
+	"float_bits": {
+		description: "IEEE-754 bits of the number as a 64-bit float",
+		system:      Hex,
+		fixedSystem: true,
+		eval: func(zs *ZappacState, arg NumberNode) (NumberNode, error) {
+			f64, err := arg.toFloat64()
+			if err != nil {
+				return emptyNumber, err
+			}
+			bits := math.Float64bits(f64)
+			return newNumber(-1, "0x"+strconv.FormatUint(bits, 16), Hex), nil
+		},
+	},
+	"sign": {
+		description: "-1, 0, or 1 depending on the sign of the number",
+		eval: func(zs *ZappacState, arg NumberNode) (NumberNode, error) {
+			f64, err := arg.toFloat64()
+			if err != nil {
+				return emptyNumber, err
+			}
+
+			// Comparisons rather than math.Signbit so -0 counts as 0
+			if f64 < 0 {
+				return newFloatNumber(-1), nil
+			} else if f64 > 0 {
+				return newFloatNumber(1), nil
+			}
+			return newFloatNumber(0), nil
+		},
+	},
+	"popcount": {
+		description: "number of set bits at the current bit width",
+		system:      Dec,
+		fixedSystem: true,
+		eval: func(zs *ZappacState, arg NumberNode) (NumberNode, error) {
+			i64, err := integerArg("popcount", arg)
+			if err != nil {
+				return emptyNumber, err
+			}
+			return newFloatNumber(float64(bits.OnesCount64(zs.toWidth(i64)))), nil
+		},
+	},
+	"clz": {
+		description: "number of leading zero bits at the current bit width",
+		system:      Dec,
+		fixedSystem: true,
+		eval: func(zs *ZappacState, arg NumberNode) (NumberNode, error) {
+			i64, err := integerArg("clz", arg)
+			if err != nil {
+				return emptyNumber, err
+			}
+			zeros := bits.LeadingZeros64(zs.toWidth(i64)) - (64 - zs.bitWidth())
+			return newFloatNumber(float64(zeros)), nil
+		},
+	},
+	"modpow": {
+		description: "base ** exponent % modulus for integers, without overflowing with large exponents",
+		arity:       3,
+		evalArgs: func(zs *ZappacState, args []NumberNode) (NumberNode, error) {
+			values := make([]*big.Int, len(args))
+			for i, arg := range args {
+				i64, err := integerArg("modpow", arg)
+				if err != nil {
+					return emptyNumber, err
+				}
+				values[i] = big.NewInt(i64)
+			}
+
+			if values[1].Sign() < 0 {
+				return emptyNumber, &OperationError{"modpow()", args[1].Value, "requires a non-negative exponent"}
+			}
+			if values[2].Sign() <= 0 {
+				return emptyNumber, &OperationError{"modpow()", args[2].Value, "requires a positive modulus"}
+			}
+
+			result := new(big.Int).Exp(values[0], values[1], values[2])
+			return newNumber(-1, result.String(), Dec), nil
+		},
+	},
+	"pctchange": {
+		description: "percentage change from old to new, ((new - old) / old) * 100",
+		system:      Dec,
+		fixedSystem: true,
+		arity:       2,
+		evalArgs: func(zs *ZappacState, args []NumberNode) (NumberNode, error) {
+			values := make([]float64, len(args))
+			for i, arg := range args {
+				f64, err := arg.toFloat64()
+				if err != nil {
+					return emptyNumber, err
+				}
+				values[i] = f64
+			}
+
+			if values[0] == 0 {
+				return emptyNumber, &OperationError{Operation: "pctchange()", Reason: "requires a non-zero old value"}
+			}
+
+			return newFloatNumber((values[1] - values[0]) / values[0] * 100), nil
+		},
+	},
+	"logn": {
+		description: "logarithm of the value in the given base, e.g. logn(8, 2) is 3",
+		system:      Dec,
+		fixedSystem: true,
+		arity:       2,
+		evalArgs: func(zs *ZappacState, args []NumberNode) (NumberNode, error) {
+			values := make([]float64, len(args))
+			for i, arg := range args {
+				f64, err := arg.toFloat64()
+				if err != nil {
+					return emptyNumber, err
+				}
+				values[i] = f64
+			}
+
+			if values[0] <= 0 {
+				return emptyNumber, &OperationError{"logn()", args[0].String(), "requires a positive value"}
+			}
+			if values[1] <= 0 || values[1] == 1 {
+				return emptyNumber, &OperationError{"logn()", args[1].String(), "requires a positive base other than 1"}
+			}
+
+			result := math.Log(values[0]) / math.Log(values[1])
+			// Exact powers give exact results, e.g. logn(1000, 10) is 3 rather than 2.9999999999999996
+			if rounded := math.Round(result); math.Pow(values[1], rounded) == values[0] {
+				result = rounded
+			}
+			return newFloatNumber(result), nil
+		},
+	},
+	"ceildiv": {
+		description: "division rounded up, e.g. ceildiv(7, 2) is 4",
+		arity:       2,
+		evalArgs: func(zs *ZappacState, args []NumberNode) (NumberNode, error) {
+			l, err := args[0].toRat()
+			if err != nil {
+				return emptyNumber, err
+			}
+
+			r, err := args[1].toRat()
+			if err != nil {
+				return emptyNumber, err
+			}
+
+			if r.Sign() == 0 {
+				return emptyNumber, fmt.Errorf("division by zero")
+			}
+
+			// The denominator is always positive, so Div rounds down and negating around it rounds up
+			quotient := new(big.Rat).Quo(l, r)
+			num := new(big.Int).Neg(quotient.Num())
+			num.Div(num, quotient.Denom())
+			return newNumber(-1, num.Neg(num).String(), Dec), nil
+		},
+	},
+	"rotl": {
+		description: "rotate the bits left at the current bit width, e.g. rotl(0x80000000, 1)",
+		arity:       2,
+		evalArgs: func(zs *ZappacState, args []NumberNode) (NumberNode, error) {
+			return zs.rotate("rotl", args, 1)
+		},
+	},
+	"rotr": {
+		description: "rotate the bits right at the current bit width, e.g. rotr(1, 1)",
+		arity:       2,
+		evalArgs: func(zs *ZappacState, args []NumberNode) (NumberNode, error) {
+			return zs.rotate("rotr", args, -1)
+		},
+	},
+	"isprime": {
+		description: "1 if the integer is prime, 0 if not",
+		system:      Dec,
+		fixedSystem: true,
+		eval: func(zs *ZappacState, arg NumberNode) (NumberNode, error) {
+			n, err := naturalArg("isprime", arg)
+			if err != nil {
+				return emptyNumber, err
+			}
+
+			// 20 Miller-Rabin rounds on top of Baillie-PSW, exact for anything below 2**64
+			if n.ProbablyPrime(20) {
+				return newFloatNumber(1), nil
+			}
+			return newFloatNumber(0), nil
+		},
+	},
+	"nextprime": {
+		description: "the smallest prime greater than the integer, e.g. nextprime(10) is 11",
+		eval: func(zs *ZappacState, arg NumberNode) (NumberNode, error) {
+			n, err := naturalArg("nextprime", arg)
+			if err != nil {
+				return emptyNumber, err
+			}
+
+			if n.BitLen() > maxPrimeBits {
+				return emptyNumber, ErrorResultTooLarge
+			}
+
+			one := big.NewInt(1)
+			candidate := new(big.Int).Add(n, one)
+			for !candidate.ProbablyPrime(20) {
+				if zs.cancelled() {
+					return emptyNumber, context.Canceled
+				}
+				candidate.Add(candidate, one)
+			}
+			return newNumber(-1, candidate.String(), Dec), nil
+		},
+	},
+	"isqrt": {
+		description: "the square root rounded down to an integer, exact for integers of any size",
+		eval: func(zs *ZappacState, arg NumberNode) (NumberNode, error) {
+			n, err := naturalArg("isqrt", arg)
+			if err != nil {
+				return emptyNumber, err
+			}
+			return newNumber(-1, new(big.Int).Sqrt(n).String(), Dec), nil
+		},
+	},
+	"convert": {
+		description: "converts the digits from one base to another, e.g. convert(\"ff\", 16, 10) is 255 and convert(\"255\", 10, 36) is 36#73",
+		arity:       3,
+		evalString: func(zs *ZappacState, digits string, args []NumberNode) (NumberNode, error) {
+			bases := make([]int64, len(args))
+			for i, arg := range args {
+				var err error
+				if bases[i], err = integerArg("convert", arg); err != nil {
+					return emptyNumber, err
+				}
+				if bases[i] < 2 || bases[i] > 36 {
+					return emptyNumber, &OperationError{"convert()", arg.Value, "requires bases from 2 to 36"}
+				}
+			}
+
+			// Any number of digits, e.g. convert("ffffffffffffffff", 16, 10) doesn't fit an int64
+			value, ok := new(big.Int).SetString(digits, int(bases[0]))
+			if !ok {
+				return emptyNumber, &OperationError{"convert()", digits, fmt.Sprintf("requires valid digits in base %d", bases[0])}
+			}
+
+			system, ok := baseSystems[bases[1]]
+			if !ok {
+				// No number system for the base, the value stays decimal and is only shown in the base, e.g. 36#73
+				result := newNumber(-1, value.String(), Dec)
+				result.Base = int(bases[1])
+				return result, nil
+			}
+
+			result, err := convertNumber(newNumber(-1, value.String(), Dec), system)
+			if err != nil {
+				return emptyNumber, err
+			}
+			return newNumber(-1, result, system), nil
+		},
+		detectSystem: func(args []Node) (NumberSystem, bool) {
+			argNodes := splitArgs(args)
+			if len(argNodes) != 3 || len(argNodes[2]) != 1 || argNodes[2][0].Type() != NodeNumber {
+				return Dec, false
+			}
+
+			base, err := argNodes[2][0].(NumberNode).toInt64()
+			if err != nil {
+				return Dec, false
+			}
+			system, ok := baseSystems[base]
+			return system, ok
+		},
+	},
+	"defined": {
+		description: "1 if the variable exists, 0 if not",
+		system:      Dec,
+		fixedSystem: true,
+		evalNodes: func(zs *ZappacState, args []Node) (NumberNode, error) {
+			if len(args) != 1 || args[0].Type() != NodeVariable {
+				return emptyNumber, &OperationError{Operation: "defined()", Reason: "requires a single variable, e.g. defined($foo)"}
+			}
+
+			variable, _ := args[0].(VariableNode)
+			if _, ok := zs.Variables[variable.Name]; ok {
+				return newFloatNumber(1), nil
+			}
+			return newFloatNumber(0), nil
+		},
+	},
+}
+
+// argCount gives the number of arguments the function takes
+func (fn function) argCount() int {
+	if fn.arity == 0 {
+		return 1
+	}
+	return fn.arity
+}
+
+// rotate rotates the bits of the first argument by the second at the current bit width, direction is 1 for left
+// and -1 for right
+func (zs *ZappacState) rotate(name string, args []NumberNode, direction int) (NumberNode, error) {
+	value, err := integerArg(name, args[0])
+	if err != nil {
+		return emptyNumber, err
+	}
+
+	count, err := integerArg(name, args[1])
+	if err != nil {
+		return emptyNumber, err
+	}
+
+	width := zs.bitWidth()
+	n := int(count%int64(width)) * direction
+	truncated := zs.toWidth(value)
+
+	var rotated uint64
+	if width == 64 {
+		rotated = bits.RotateLeft64(truncated, n)
+	} else {
+		n = (n + width) % width
+		rotated = (truncated<<n | truncated>>(width-n)) & (1<<width - 1)
+	}
+
+	return newNumber(-1, strconv.FormatUint(rotated, 10), Dec), nil
+}
+
+// integerArg reads the argument of the named function as an integer
+func integerArg(name string, arg NumberNode) (int64, error) {
+	i64, err := arg.toInt64()
+	if err != nil {
+		return 0, &OperationError{name + "()", arg.Value, "requires an integer"}
+	}
+	return i64, nil
+}
+
+// naturalArg reads the argument of the named function as a non-negative integer of any size
+func naturalArg(name string, arg NumberNode) (*big.Int, error) {
+	r, err := arg.toRat()
+	if err != nil || !r.IsInt() {
+		return nil, &OperationError{name + "()", arg.Value, "requires an integer"}
+	}
+	if r.Sign() < 0 {
+		return nil, &OperationError{name + "()", arg.Value, "requires a non-negative integer"}
+	}
+	return r.Num(), nil
+}
+
+// splitArgs splits the nodes at the commas outside of parenthesis, e.g. into the arguments of a function
+func splitArgs(nodes []Node) [][]Node {
+	args := [][]Node{}
+	depth := 0
+	argStart := 0
+
+	for i, node := range nodes {
+		switch node.Type() {
+		case NodeLParen:
+			depth++
+		case NodeRParen:
+			depth--
+		case NodeComma:
+			if depth == 0 {
+				args = append(args, nodes[argStart:i])
+				argStart = i + 1
+			}
+		}
+	}
+
+	return append(args, nodes[argStart:])
+}
+
+// stringArg splits off the string that starts the arguments of a function, e.g. "ff" in convert("ff", 16, 10)
+func stringArg(name string, nodes []Node) (string, []Node, error) {
+	if len(nodes) == 0 || nodes[0].Type() != NodeString || (len(nodes) > 1 && nodes[1].Type() != NodeComma) {
+		return "", nil, &OperationError{Operation: name + "()", Reason: "requires a string as the first argument"}
+	}
+
+	text := nodes[0].(StringNode).Value
+	if len(nodes) == 1 {
+		return text, nil, nil
+	}
+	return text, nodes[2:], nil
+}
+
+// functionArgs evaluates the comma separated arguments of a function
+func (zs *ZappacState) functionArgs(nodes []Node) ([]NumberNode, error) {
+	args := []NumberNode{}
+	for _, argNodes := range splitArgs(nodes) {
+		arg, err := zs.pemdas(argNodes)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// callFunction evaluates the function at funcPos with its arguments and replaces it in the node tree
+func (zs *ZappacState) callFunction(nodes []Node, funcPos int) (result []Node, err error) {
+	name := nodes[funcPos].String()
+	fn, ok := functions[name]
+	if !ok {
+		err = fmt.Errorf("unknown function %s", name)
+		return
+	}
+
+	// Parser makes sure functions are always followed by their parenthesis
+	start := funcPos + 1
+	offset := findClosing(nodes[start:])
+	if offset == -1 {
+		err = ErrorUnbalancedParentheses
+		return
+	}
+	closing := start + offset
+
+	var value NumberNode
+	if fn.evalNodes != nil {
+		value, err = fn.evalNodes(zs, nodes[start+1:closing])
+	} else {
+		argNodes := nodes[start+1 : closing]
+		text := ""
+		if fn.evalString != nil {
+			if text, argNodes, err = stringArg(name, argNodes); err != nil {
+				return
+			}
+		}
+
+		var args []NumberNode
+		if len(argNodes) > 0 || fn.evalString == nil {
+			args, err = zs.functionArgs(argNodes)
+			if err != nil {
+				return
+			}
+		}
+
+		count := len(args)
+		if fn.evalString != nil {
+			count++
+		}
+
+		if count != fn.argCount() {
+			plural := "s"
+			if fn.argCount() == 1 {
+				plural = ""
+			}
+			err = fmt.Errorf("%s() takes %d argument%s, got %d", name, fn.argCount(), plural, count)
+			return
+		}
+
+		if fn.evalString != nil {
+			value, err = fn.evalString(zs, text, args)
+		} else if fn.evalArgs != nil {
+			value, err = fn.evalArgs(zs, args)
+		} else {
+			value, err = fn.eval(zs, args[0])
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	if value.System == Dec {
+		var f64 float64
+		if f64, err = value.toFloat64(); err != nil {
+			return
+		}
+		if err = checkFinite(f64); err != nil {
+			err = fmt.Errorf("%s(): %w", name, err)
+			return
+		}
+	}
+
+	result = replace(nodes, funcPos, closing, value)
+	return
+}
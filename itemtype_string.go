@@ -11,38 +11,54 @@ func _() {
 	_ = x[itemError-0]
 	_ = x[itemEOF-1]
 	_ = x[itemEquals-2]
-	_ = x[itemSpace-3]
-	_ = x[itemLParen-4]
-	_ = x[itemRParen-5]
-	_ = x[itemNumber-6]
-	_ = x[itemVariable-7]
-	_ = x[itemAdd-8]
-	_ = x[itemSub-9]
-	_ = x[itemMult-10]
-	_ = x[itemExp-11]
-	_ = x[itemDiv-12]
-	_ = x[itemFdiv-13]
-	_ = x[itemAnd-14]
-	_ = x[itemOr-15]
-	_ = x[itemXor-16]
-	_ = x[itemInv-17]
-	_ = x[itemMod-18]
-	_ = x[itemLShift-19]
-	_ = x[itemRShift-20]
-	_ = x[itemText-21]
-	_ = x[itemAbs-22]
-	_ = x[itemSave-23]
-	_ = x[itemLoad-24]
-	_ = x[itemDec-25]
-	_ = x[itemHex-26]
-	_ = x[itemBin-27]
-	_ = x[itemOct-28]
-	_ = x[itemClear-29]
+	_ = x[itemInlineAssign-3]
+	_ = x[itemCompoundAssign-4]
+	_ = x[itemSpace-5]
+	_ = x[itemNewline-6]
+	_ = x[itemLParen-7]
+	_ = x[itemRParen-8]
+	_ = x[itemComma-9]
+	_ = x[itemNumber-10]
+	_ = x[itemVariable-11]
+	_ = x[itemUnit-12]
+	_ = x[itemString-13]
+	_ = x[itemAdd-14]
+	_ = x[itemSub-15]
+	_ = x[itemMult-16]
+	_ = x[itemExp-17]
+	_ = x[itemDiv-18]
+	_ = x[itemFdiv-19]
+	_ = x[itemAnd-20]
+	_ = x[itemOr-21]
+	_ = x[itemXor-22]
+	_ = x[itemInv-23]
+	_ = x[itemMod-24]
+	_ = x[itemLShift-25]
+	_ = x[itemRShift-26]
+	_ = x[itemLt-27]
+	_ = x[itemLte-28]
+	_ = x[itemGt-29]
+	_ = x[itemGte-30]
+	_ = x[itemEq-31]
+	_ = x[itemNe-32]
+	_ = x[itemText-33]
+	_ = x[itemAbs-34]
+	_ = x[itemFunction-35]
+	_ = x[itemSave-36]
+	_ = x[itemLoad-37]
+	_ = x[itemDec-38]
+	_ = x[itemHex-39]
+	_ = x[itemBin-40]
+	_ = x[itemOct-41]
+	_ = x[itemClear-42]
+	_ = x[itemWhere-43]
+	_ = x[itemHelp-44]
+	_ = x[itemInspect-45]
 }
 
-const _ItemType_name = "itemErroritemEOFitemEqualsitemSpaceitemLParenitemRParenitemNumberitemVariableitemAdditemSubitemMultitemExpitemDivitemFdivitemAnditemOritemXoritemInvitemModitemLShiftitemRShiftitemTextitemAbsitemSaveitemLoaditemDecitemHexitemBinitemOctitemClear"
+const _ItemType_name = "itemErroritemEOFitemEqualsitemInlineAssignitemCompoundAssignitemSpaceitemNewlineitemLParenitemRParenitemCommaitemNumberitemVariableitemUnititemStringitemAdditemSubitemMultitemExpitemDivitemFdivitemAnditemOritemXoritemInvitemModitemLShiftitemRShiftitemLtitemLteitemGtitemGteitemEqitemNeitemTextitemAbsitemFunctionitemSaveitemLoaditemDecitemHexitemBinitemOctitemClearitemWhereitemHelpitemInspect"
 
-var _ItemType_index = [...]uint8{0, 9, 16, 26, 35, 45, 55, 65, 77, 84, 91, 99, 106, 113, 121, 128, 134, 141, 148, 155, 165, 175, 183, 190, 198, 206, 213, 220, 227, 234, 243}
+var _ItemType_index = [...]uint16{0, 9, 16, 26, 42, 60, 69, 80, 90, 100, 109, 119, 131, 139, 149, 156, 163, 171, 178, 185, 193, 200, 206, 213, 220, 227, 237, 247, 253, 260, 266, 273, 279, 285, 293, 300, 312, 320, 328, 335, 342, 349, 356, 365, 374, 382, 393}
 
 func (i ItemType) String() string {
 	if i < 0 || i >= ItemType(len(_ItemType_index)-1) {
@@ -5,7 +5,9 @@ package zappaclang
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -20,20 +22,26 @@ $foo * 2
 9 | 0
 0xff << 10
 0xaa >> 1
+1 < $foo <= 10
 ~9
 2 ^ 3 % 7
 abs(-5) - 5
 save(foo)
-load(bar)
+load("my profile")
 dec(b111)
 hex(0400)
 bin(123 ** 2)
 oct(5+$foo)
+$foo = hex(255)
 
 ---
 
 $foo = variable
-11, -195, 0xff, 0777, b100 = number
+$profile::foo = variable in a saved profile
+11, -195, 0xff, 0777, b100, 1.5e3 = number
+100px = number with unit px
+€100, £100 = number, the currency symbol is ignored
+10k = 10000 when magnitude suffixes k m g ki mi gi are enabled, otherwise a unit
 ( ) = parenthesis
 + = add
 - = sub
@@ -42,31 +50,44 @@ $foo = variable
 / = div
 // = fdiv
 & = bitwise and
-| = bitwise or
+| = bitwise or, or absolute value like |-5| where an operator can't be
 ^ = bitwise xor
 ~ = bitwise inversion
-% = modulus
+% = modulus, or percent when assigning a single number, e.g. $rate = 5% is 0.05
 << = lshift
 >> = rshift
+< <= > >= == != = comparisons giving 1 or 0, can be chained like 1 < $foo < 10
 abs = absolute
+float_bits = IEEE-754 bits of a float
+sign = sign of a number as -1, 0, or 1
+popcount = number of set bits
+clz = number of leading zero bits
+defined = 1 if variable exists, 0 if not
+modpow = modular exponentiation, e.g. modpow(2, 10, 1000)
+pctchange = percentage change, e.g. pctchange(100, 150)
+rotl rotr = rotate bits left or right at the bit width, e.g. rotl(0x80000000, 1)
+logn = logarithm in any base, e.g. logn(8, 2)
+ceildiv = division rounded up, e.g. ceildiv(7, 2)
+isprime = 1 if the integer is prime, 0 if not, e.g. isprime(7)
+isqrt = integer square root rounded down, e.g. isqrt(17)
+convert = converts digits between bases 2 to 36, e.g. convert("ff", 16, 10), other bases than 2, 8, 10, and 16 are shown like 36#73
+nextprime = the smallest prime greater than the integer, e.g. nextprime(10)
+pi e phi sqrt2 sqrt1_2 = constants, text that the parser reads as numbers where a value is expected
+, = separates function arguments, or the variables and values of $a, $b = 1, 2
 = = equals
-+= TODO: plus equals
--= TODO: minus equals
-*= TODO: mult equals
-/= TODO: div equals
-//= TODO: fdiv equals
-**= TODO: exp equals
-&= TODO: and equals
-|= TODO: or equals
-^= TODO: xor equals
+:= = inline assignment, e.g. ($foo := 5) + $foo
++= -= *= /= //= **= &= |= ^= %= <<= >>= = compound assignment, e.g. $foo **= 2 is $foo = $foo ** (2)
 ~= TODO: invert equals
-%= TODO: mod equals
-<<= TODO: lshift equals
->>= TODO: rshift equals
 save = save
 load = load
+where = where profiles are saved
+help = list the operators, functions and commands
+inspect = list the variables and settings
+# = comment until the end of the line
+newlines = separate statements, other whitespace is condensed to a single space
+"name" = quoted string for save and load, supporting \" \\ and \n escapes
 dec = decimal output
-hex = hexadecimal output
+hex = hexadecimal output, $foo = hex(255) also shows $foo in hex later
 bin = binary output
 oct = octal output
 */
@@ -79,41 +100,89 @@ type Pos int
 type ItemType int
 
 const (
-	itemError    ItemType = iota // error occurred; value is text of error
-	itemEOF                      // End of input
-	itemEquals                   // '=', assignment
-	itemSpace                    // whitespace
-	itemLParen                   // '('
-	itemRParen                   // ')'
-	itemNumber                   // numbers like 135, 1.23, 0x7f, b0100, 0755
-	itemVariable                 // variable starting with '$', e.g. '$hello'
-	itemAdd                      // + add
-	itemSub                      // - substract
-	itemMult                     // * multiply
-	itemExp                      // ** exponent
-	itemDiv                      // / division
-	itemFdiv                     // // floor-div
-	itemAnd                      // &	bitwise and
-	itemOr                       // | bitwise or
-	itemXor                      // ^ bitwise xor
-	itemInv                      // ~ bitwise inversion
-	itemMod                      // % modulus
-	itemLShift                   // << left shift
-	itemRShift                   // >> right shift
-	// TODO: += .. >>=
+	itemError          ItemType = iota // error occurred; value is text of error
+	itemEOF                            // End of input
+	itemEquals                         // '=', assignment
+	itemInlineAssign                   // ':=', inline assignment
+	itemCompoundAssign                 // '+=', '**=' and so on, the value is the operator
+	itemSpace                          // whitespace within a line
+	itemNewline                        // whitespace with newlines, separating statements
+	itemLParen                         // '('
+	itemRParen                         // ')'
+	itemComma                          // ',' between function arguments
+	itemNumber                         // numbers like 135, 1.23, 1e3, 0x7f, b0100, 0755
+	itemVariable                       // variable starting with '$', e.g. '$hello'
+	itemUnit                           // unit directly following a number, e.g. 'px' in '100px'
+	itemString                         // quoted string, e.g. '"my profile"', the value has the escapes resolved
+	itemAdd                            // + add
+	itemSub                            // - substract
+	itemMult                           // * multiply
+	itemExp                            // ** exponent
+	itemDiv                            // / division
+	itemFdiv                           // // floor-div
+	itemAnd                            // &	bitwise and
+	itemOr                             // | bitwise or
+	itemXor                            // ^ bitwise xor
+	itemInv                            // ~ bitwise inversion
+	itemMod                            // % modulus
+	itemLShift                         // << left shift
+	itemRShift                         // >> right shift
+	itemLt                             // < less than
+	itemLte                            // <= less than or equal
+	itemGt                             // > greater than
+	itemGte                            // >= greater than or equal
+	itemEq                             // == equal
+	itemNe                             // != not equal
 	// The plain text things rely on being after itemText for simplified stringification
-	itemText // plain text
-	itemAbs  // abs() - calculate absolute value
+	itemText     // plain text
+	itemAbs      // abs() - calculate absolute value
+	itemFunction // other functions, e.g. float_bits()
 	// The following can only exist at the start of the line
-	itemSave  // save state
-	itemLoad  // load state
-	itemDec   // dec()
-	itemHex   // hex()
-	itemBin   // bin()
-	itemOct   // oct()
-	itemClear // oct()
+	itemSave    // save state
+	itemLoad    // load state
+	itemDec     // dec()
+	itemHex     // hex()
+	itemBin     // bin()
+	itemOct     // oct()
+	itemClear   // clear()
+	itemWhere   // where()
+	itemHelp    // help()
+	itemInspect // inspect()
 )
 
+// operatorItemMap maps the operator symbols to their item types
+var operatorItemMap = map[string]ItemType{
+	"+":  itemAdd,
+	"-":  itemSub,
+	"*":  itemMult,
+	"**": itemExp,
+	"/":  itemDiv,
+	"//": itemFdiv,
+	"&":  itemAnd,
+	"|":  itemOr,
+	"^":  itemXor,
+	"~":  itemInv,
+	"%":  itemMod,
+	"<<": itemLShift,
+	">>": itemRShift,
+	"<":  itemLt,
+	"<=": itemLte,
+	">":  itemGt,
+	">=": itemGte,
+	"==": itemEq,
+	"!=": itemNe,
+}
+
+// compoundAssignOperators can be followed by = for compound assignment, longest first so e.g. **= isn't lexed as *=
+var compoundAssignOperators = []string{"**", "//", "<<", ">>", "+", "-", "*", "/", "&", "|", "^", "%"}
+
+// DefaultOperatorAliases are the common Unicode spellings of operators
+var DefaultOperatorAliases = map[string]string{
+	"×": "*",
+	"÷": "/",
+	"−": "-",
+}
+
 var operatorItems = []ItemType{
 	itemAdd,
 	itemSub,
@@ -128,6 +197,12 @@ var operatorItems = []ItemType{
 	itemMod,
 	itemLShift,
 	itemRShift,
+	itemLt,
+	itemLte,
+	itemGt,
+	itemGte,
+	itemEq,
+	itemNe,
 }
 
 //go:generate stringer -type=ItemType
@@ -141,6 +216,7 @@ const (
 	hexadecimal     = digits + "abcdefABCDEF"
 	binary          = "01"
 	alnum           = letters + digits
+	currencySymbols = "€£"
 )
 
 type item struct {
@@ -156,7 +232,7 @@ func (i item) String() string {
 		return "EOF"
 	case i.typ == itemError:
 		return i.val
-	case i.typ == itemLParen || i.typ == itemRParen:
+	case i.typ == itemLParen || i.typ == itemRParen || i.typ == itemComma:
 		return i.val
 	case i.typ >= itemAdd && i.typ < itemText:
 		return i.val
@@ -169,14 +245,25 @@ func (i item) String() string {
 	return fmt.Sprintf("<%s>%q", name, i.val)
 }
 
+// lexConfig holds the optional customizations of the scanner.
+type lexConfig struct {
+	aliases        map[string]string // alternative spellings of operators, e.g. × for *
+	variablePrefix rune              // starts variable names instead of $, variables are still emitted with $
+}
+
 // lexer holds the state of the scanner.
 type lexer struct {
-	input string // the string being scanned
-	pos   Pos    // current position in the input
-	start Pos    // start position of this item
-	len   Pos
-	atEOF bool      // we have hit the end of input and returned eof
-	items chan item // channel to send items through
+	input      string // the string being scanned
+	pos        Pos    // current position in the input
+	start      Pos    // start position of this item
+	len        Pos
+	atEOF      bool      // we have hit the end of input and returned eof
+	hitEOF     bool      // like atEOF, but never reset, used by Scanner to know when more input could change the items
+	items      chan item // channel to send items through, nil when the items are collected in pending
+	pending    []item    // items waiting to be taken by the Scanner
+	state      stateFn   // next state to run for the Scanner
+	config     lexConfig
+	aliasOrder []string // aliases from longest to shortest, so the longest match wins
 }
 
 // stateFn represents the state of the scanner as a function that returns the next state.
@@ -186,6 +273,7 @@ type stateFn func(*lexer) stateFn
 func (l *lexer) next() rune {
 	if int(l.pos) >= len(l.input) {
 		l.atEOF = true
+		l.hitEOF = true
 		return eof
 	}
 	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
@@ -194,17 +282,19 @@ func (l *lexer) next() rune {
 }
 
 // peek returns but does not consume the next rune in the input.
-/*
 func (l *lexer) peek() rune {
 	r := l.next()
 	l.backup()
 	return r
 }
-*/
 
-// backup steps back one rune.
+// backup steps back one rune. Backing up from eof only undoes reading eof, as it never moved the position.
 func (l *lexer) backup() {
-	if !l.atEOF && l.pos > 0 {
+	if l.atEOF {
+		l.atEOF = false
+		return
+	}
+	if l.pos > 0 {
 		_, w := utf8.DecodeLastRuneInString(l.input[:l.pos])
 		l.pos -= Pos(w)
 	}
@@ -228,7 +318,11 @@ func (l *lexer) emitItem(i item) stateFn {
 	if debugLex {
 		fmt.Printf("LEX -> %s\n", i.val)
 	}
-	l.items <- i
+	if l.items == nil {
+		l.pending = append(l.pending, i)
+	} else {
+		l.items <- i
+	}
 	return nil
 }
 
@@ -281,15 +375,39 @@ func (l *lexer) debug(location string) {
 
 // lex creates a new scanner for the input string.
 func lex(input string) (*lexer, chan item) {
-	l := &lexer{
-		input: input,
-		len:   Pos(len(input)),
-		items: make(chan item),
-	}
+	return lexWithConfig(input, lexConfig{})
+}
+
+// lexWithConfig creates a new scanner for the input string with customizations.
+func lexWithConfig(input string, config lexConfig) (*lexer, chan item) {
+	l := newLexer(input, config)
+	l.items = make(chan item)
 	go l.run()
 	return l, l.items
 }
 
+// newLexer creates a new scanner that collects items in pending instead of running in a goroutine.
+func newLexer(input string, config lexConfig) *lexer {
+	l := &lexer{
+		input:  input,
+		len:    Pos(len(input)),
+		state:  lexBase,
+		config: config,
+	}
+
+	for alias := range config.aliases {
+		l.aliasOrder = append(l.aliasOrder, alias)
+	}
+	sort.Slice(l.aliasOrder, func(i, j int) bool {
+		if len(l.aliasOrder[i]) != len(l.aliasOrder[j]) {
+			return len(l.aliasOrder[i]) > len(l.aliasOrder[j])
+		}
+		return l.aliasOrder[i] < l.aliasOrder[j]
+	})
+
+	return l
+}
+
 type lexMapItem struct {
 	key     string
 	stateFn stateFn
@@ -301,10 +419,14 @@ func lexBase(l *lexer) stateFn {
 	// TODO: Would be nice if this could be on higher level and wouldn't need to be redefined
 	// ... but since these functions have references to lexBase, which uses this map, it apparently doesn't work
 	lexMap := []lexMapItem{
-		{"$", lexVariable},
+		{l.variablePrefix(), lexVariable},
+		{"\"", lexString},
 		{"(", lexLParen},
 		{")", lexRParen},
+		{",", lexComma},
+		{"==", lexEq},
 		{"=", lexEquals},
+		{":=", lexInlineAssign},
 		{"+", lexAdd},
 		{"-", lexSub},
 		{"**", lexExp},
@@ -318,13 +440,44 @@ func lexBase(l *lexer) stateFn {
 		{"%", lexMod},
 		{"<<", lexLShift},
 		{">>", lexRShift},
+		{"<=", lexLte},
+		{"<", lexLt},
+		{">=", lexGte},
+		{">", lexGt},
+		{"!=", lexNe},
 	}
 
-	// Any leading whitespace is condensed to one
+	// Any leading whitespace is condensed to one, keeping newlines as they separate statements
 	l.acceptRun(whitespaceChars)
 	if l.pos > l.start {
+		if strings.Contains(l.input[l.start:l.pos], "\n") {
+			l.emitItem(item{itemNewline, l.start, l.pos, "\n"})
+		} else {
+			l.emitItem(item{itemSpace, l.start, l.pos, " "})
+		}
+		l.ignore()
+	}
+
+	// Comments last until the end of the line
+	if strings.HasPrefix(l.input[l.pos:], "#") {
+		for r := l.next(); r != '\n' && r != eof; r = l.next() {
+		}
+		l.backup()
 		l.ignore()
-		l.emitItem(item{itemSpace, l.start, l.pos, " "})
+		return lexBase
+	}
+
+	// Compound assignment like **=, but not comparisons like |$foo|==1
+	rest := l.input[l.pos:]
+	for _, op := range compoundAssignOperators {
+		// The Scanner needs to wait for more input to tell e.g. *= from **= or |== apart
+		if rest != "" && len(rest) < len(op)+2 && strings.HasPrefix(op+"==", rest) {
+			l.hitEOF = true
+		}
+
+		if strings.HasPrefix(rest, op+"=") && !strings.HasPrefix(rest, op+"==") {
+			return lexCompoundAssign(op)
+		}
 	}
 
 	for _, lexMapItem := range lexMap {
@@ -333,10 +486,19 @@ func lexBase(l *lexer) stateFn {
 		}
 	}
 
-	if l.accept("b") && l.accept(binary) {
-		l.backup()
+	for _, alias := range l.aliasOrder {
+		if l.hasAlias(alias) {
+			return lexAlias(alias)
+		}
+	}
+
+	if l.accept("b") {
+		if l.accept(binary) {
+			l.backup()
+			l.backup()
+			return lexNumber
+		}
 		l.backup()
-		return lexNumber
 	}
 
 	if l.accept(digits) {
@@ -344,6 +506,17 @@ func lexBase(l *lexer) stateFn {
 		return lexNumber
 	}
 
+	// Currency symbols directly before a number are ignored, e.g. €100 is 100. $ always starts a variable.
+	if l.accept(currencySymbols) {
+		if l.accept(digits) {
+			l.backup()
+			l.ignore()
+			return lexNumber
+		}
+		l.pos = l.start
+		l.atEOF = false
+	}
+
 	if l.accept(letters) {
 		l.backup()
 		return lexText
@@ -354,61 +527,153 @@ func lexBase(l *lexer) stateFn {
 		return nil
 	}
 
+	// Digits from other scripts are easy to paste by accident, e.g. full-width １２３
+	if r := l.peek(); unicode.IsDigit(r) {
+		return l.errorf("Unexpected non-ASCII digit %c, numbers can only use 0-9", r)
+	}
+
 	// Any other options?
 	return l.errorf("Unexpected %c", l.next())
 }
 
+// hasAlias checks if the alias is next in the input, word aliases like "mod" must not continue with more letters
+func (l *lexer) hasAlias(alias string) bool {
+	if !strings.HasPrefix(l.input[l.pos:], alias) {
+		return false
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(alias)
+	if !strings.ContainsRune(letters, last) {
+		return true
+	}
+
+	following, _ := utf8.DecodeRuneInString(l.input[int(l.pos)+len(alias):])
+	return !strings.ContainsRune(letters+"_", following)
+}
+
+// lexAlias emits the alias as the operator it is an alias for
+func lexAlias(alias string) stateFn {
+	return func(l *lexer) stateFn {
+		l.debug("alias")
+
+		op := l.config.aliases[alias]
+		typ, ok := operatorItemMap[op]
+		if !ok {
+			return l.errorf("Unknown operator %s for alias %s", op, alias)
+		}
+
+		l.pos += Pos(len(alias))
+		l.emitItem(item{typ, l.start, l.pos, op})
+		l.ignore()
+		return lexBase
+	}
+}
+
 func lexVariable(l *lexer) stateFn {
 	l.debug("variable")
 
-	// Must start with a $
-	l.accept("$")
+	// Must start with a $, $$ is a reference to the last assigned variable
+	prefix := l.variablePrefix()
+	l.accept(prefix)
+	if l.accept(prefix) {
+		l.emitItem(item{itemVariable, l.start, l.pos, lastAssignedVariable})
+		l.ignore()
+		return lexBase
+	}
 
 	validChars := letters + "_"
 	l.acceptRun(validChars)
+
+	// $profile::name references a variable in a saved profile
+	mark := l.pos
+	if l.pos > l.start+1 && l.accept(":") && l.accept(":") && l.accept(validChars) {
+		l.acceptRun(validChars)
+	} else {
+		l.pos = mark
+		l.atEOF = false
+	}
+
 	if l.pos > l.start {
-		l.emit(itemVariable)
+		// Variables are always named with $, whatever prefix they're written with
+		name := "$" + l.input[l.start+Pos(len(prefix)):l.pos]
+		l.emitItem(item{itemVariable, l.start, l.pos, name})
+		l.ignore()
 	}
 
 	return lexBase
 }
 
+// variablePrefix gives what variable names start with
+func (l *lexer) variablePrefix() string {
+	if l.config.variablePrefix == 0 {
+		return "$"
+	}
+	return string(l.config.variablePrefix)
+}
+
+func lexString(l *lexer) stateFn {
+	l.debug("string")
+
+	l.accept("\"")
+
+	var sb strings.Builder
+	for {
+		r := l.next()
+		switch r {
+		case eof:
+			return l.errorf("Unterminated string")
+		case '"':
+			l.emitItem(item{itemString, l.start, l.pos, sb.String()})
+			l.ignore()
+			return lexBase
+		case '\\':
+			escaped := l.next()
+			switch escaped {
+			case '"', '\\':
+				sb.WriteRune(escaped)
+			case 'n':
+				sb.WriteRune('\n')
+			case eof:
+				return l.errorf("Unterminated string")
+			default:
+				return l.errorf("Unknown escape \\%c in string", escaped)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+// keywords maps the identifiers with a special meaning to their item types, anything else is either a function or text
+var keywords = map[string]ItemType{
+	"abs":     itemAbs,
+	"bin":     itemBin,
+	"clear":   itemClear,
+	"dec":     itemDec,
+	"help":    itemHelp,
+	"hex":     itemHex,
+	"inspect": itemInspect,
+	"load":    itemLoad,
+	"oct":     itemOct,
+	"save":    itemSave,
+	"where":   itemWhere,
+}
+
 func lexText(l *lexer) stateFn {
 	l.debug("text")
 
-	// Must start with a letter, then can be followed by letters and underscores
+	// Must start with a letter, then can be followed by letters, underscores and digits
 	l.accept(letters)
-	l.acceptRun(letters + "_")
+	l.acceptRun(letters + "_" + digits)
 
 	item := l.thisItem(itemText)
 
-	if item.val == "save" {
-		item.typ = itemSave
-		l.emitItem(item)
-	} else if item.val == "load" {
-		item.typ = itemLoad
-		l.emitItem(item)
-	} else if item.val == "clear" {
-		item.typ = itemClear
-		l.emitItem(item)
-	} else if item.val == "abs" {
-		item.typ = itemAbs
-		l.emitItem(item)
-	} else if item.val == "dec" {
-		item.typ = itemDec
-		l.emitItem(item)
-	} else if item.val == "hex" {
-		item.typ = itemHex
-		l.emitItem(item)
-	} else if item.val == "bin" {
-		item.typ = itemBin
-		l.emitItem(item)
-	} else if item.val == "oct" {
-		item.typ = itemOct
-		l.emitItem(item)
-	} else {
-		l.emitItem(item)
+	if typ, ok := keywords[item.val]; ok {
+		item.typ = typ
+	} else if _, ok := functions[item.val]; ok {
+		item.typ = itemFunction
 	}
+	l.emitItem(item)
 
 	return lexBase
 }
@@ -424,6 +689,7 @@ func lexNumber(l *lexer) stateFn {
 
 	if l.accept("0") {
 		if l.accept("xX") {
+			// e is always a hex digit here, never an exponent
 			l.acceptRun(hexadecimal)
 			l.emit(itemNumber)
 			return lexBase
@@ -442,7 +708,26 @@ func lexNumber(l *lexer) stateFn {
 			}
 		}
 
+		// Scientific notation, e.g. 1e3 or 2.5E-4
+		mark := l.pos
+		if l.accept("eE") {
+			l.accept("+-")
+			if l.accept(digits) {
+				l.acceptRun(digits)
+			} else {
+				// Not an exponent after all, e.g. the unit in 1em
+				l.pos = mark
+				l.atEOF = false
+			}
+		}
+
 		l.emit(itemNumber)
+
+		// Units can follow decimal numbers
+		if l.accept(letters) {
+			l.acceptRun(letters)
+			l.emit(itemUnit)
+		}
 	}
 
 	return lexBase
@@ -464,6 +749,14 @@ func lexRParen(l *lexer) stateFn {
 	return lexBase
 }
 
+func lexComma(l *lexer) stateFn {
+	l.debug("comma")
+
+	l.accept(",")
+	l.emit(itemComma)
+	return lexBase
+}
+
 func lexEquals(l *lexer) stateFn {
 	l.debug("equals")
 
@@ -472,6 +765,27 @@ func lexEquals(l *lexer) stateFn {
 	return lexBase
 }
 
+func lexInlineAssign(l *lexer) stateFn {
+	l.debug("inline assign")
+
+	l.accept(":")
+	l.accept("=")
+	l.emit(itemInlineAssign)
+	return lexBase
+}
+
+// lexCompoundAssign emits an operator followed by =, e.g. **=, with the operator as the value
+func lexCompoundAssign(op string) stateFn {
+	return func(l *lexer) stateFn {
+		l.debug("compound assign")
+
+		l.pos += Pos(len(op) + 1)
+		l.emitItem(item{itemCompoundAssign, l.start, l.pos, op})
+		l.ignore()
+		return lexBase
+	}
+}
+
 func lexAdd(l *lexer) stateFn {
 	l.debug("add")
 
@@ -591,3 +905,61 @@ func lexRShift(l *lexer) stateFn {
 	l.emit(itemRShift)
 	return lexBase
 }
+
+func lexLt(l *lexer) stateFn {
+	l.debug("lt")
+
+	l.accept("<")
+
+	l.emit(itemLt)
+	return lexBase
+}
+
+func lexLte(l *lexer) stateFn {
+	l.debug("lte")
+
+	l.accept("<")
+	l.accept("=")
+
+	l.emit(itemLte)
+	return lexBase
+}
+
+func lexGt(l *lexer) stateFn {
+	l.debug("gt")
+
+	l.accept(">")
+
+	l.emit(itemGt)
+	return lexBase
+}
+
+func lexGte(l *lexer) stateFn {
+	l.debug("gte")
+
+	l.accept(">")
+	l.accept("=")
+
+	l.emit(itemGte)
+	return lexBase
+}
+
+func lexEq(l *lexer) stateFn {
+	l.debug("eq")
+
+	l.accept("=")
+	l.accept("=")
+
+	l.emit(itemEq)
+	return lexBase
+}
+
+func lexNe(l *lexer) stateFn {
+	l.debug("ne")
+
+	l.accept("!")
+	l.accept("=")
+
+	l.emit(itemNe)
+	return lexBase
+}
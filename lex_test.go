@@ -2,6 +2,7 @@ package zappaclang
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -19,11 +20,22 @@ func mkItem(typ ItemType, text string) item {
 	}
 }
 
+// mkItemAt makes an item with its exact byte positions in the input
+func mkItemAt(typ ItemType, text string, pos, end Pos) item {
+	return item{
+		typ: typ,
+		pos: pos,
+		end: end,
+		val: text,
+	}
+}
+
 var (
 	tEOF    = mkItem(itemEOF, "")
 	tLpar   = mkItem(itemLParen, "(")
 	tRpar   = mkItem(itemRParen, ")")
 	tSpace  = mkItem(itemSpace, " ")
+	tNl     = mkItem(itemNewline, "\n")
 	tEquals = mkItem(itemEquals, "=")
 	tLShift = mkItem(itemLShift, "<<")
 	tRShift = mkItem(itemRShift, ">>")
@@ -38,15 +50,29 @@ var (
 
 var lexTests = []lexTest{
 	{"empty", "", []item{tEOF}},
-	{"error", "<", []item{mkItem(itemError, "Unexpected <")}},
+	{"error", "?", []item{mkItem(itemError, "Unexpected ?")}},
 
-	{"space", " \t\r\n \t\t\r\n", []item{tSpace, tEOF}},
+	{"space", " \t\r \t\t\r", []item{tSpace, tEOF}},
+	{"newline", " \t\r\n \t\t\r\n", []item{tNl, tEOF}},
+	{"statements", "1 \t+\t 2\n\n$foo  \r\n\t3", []item{
+		mkItem(itemNumber, "1"), tSpace, tAdd, tSpace, mkItem(itemNumber, "2"), tNl,
+		mkItem(itemVariable, "$foo"), tNl, mkItem(itemNumber, "3"), tEOF,
+	}},
 	{"variable", "$foo", []item{mkItem(itemVariable, "$foo"), tEOF}},
-	{"variable with space around", "  \t$foo   \n", []item{tSpace, mkItem(itemVariable, "$foo"), tSpace, tEOF}},
+	{"variable with space around", "  \t$foo   \n", []item{tSpace, mkItem(itemVariable, "$foo"), tNl, tEOF}},
+	{"last assigned variable", "$$ + 1", []item{mkItem(itemVariable, "$$"), tSpace, tAdd, tSpace, mkItem(itemNumber, "1"), tEOF}},
+	{"profile variable", "$base::x_y+$a:", []item{
+		mkItem(itemVariable, "$base::x_y"), tAdd, mkItem(itemVariable, "$a"), mkItem(itemError, "Unexpected :"),
+	}},
 	{"assign to variable", "$f_a_b_u_l_o_u_s=717", []item{mkItem(itemVariable, "$f_a_b_u_l_o_u_s"), tEquals, mkItem(itemNumber, "717"), tEOF}},
 	{"assign with spaces", "$bar   =  b001", []item{mkItem(itemVariable, "$bar"), tSpace, tEquals, tSpace, mkItem(itemNumber, "b001"), tEOF}},
 	{"lshift", "b001 << 10", []item{mkItem(itemNumber, "b001"), tSpace, tLShift, tSpace, mkItem(itemNumber, "10"), tEOF}},
 	{"rshift", "0x7f>>1", []item{mkItem(itemNumber, "0x7f"), tRShift, mkItem(itemNumber, "1"), tEOF}},
+	{"comparisons", "1<2<=3>4>=5==6!=7", []item{
+		mkItem(itemNumber, "1"), mkItem(itemLt, "<"), mkItem(itemNumber, "2"), mkItem(itemLte, "<="), mkItem(itemNumber, "3"),
+		mkItem(itemGt, ">"), mkItem(itemNumber, "4"), mkItem(itemGte, ">="), mkItem(itemNumber, "5"), mkItem(itemEq, "=="),
+		mkItem(itemNumber, "6"), mkItem(itemNe, "!="), mkItem(itemNumber, "7"), tEOF,
+	}},
 	{"simple math", "3+1*2**3/4//2%3-1", []item{
 		mkItem(itemNumber, "3"), tAdd, mkItem(itemNumber, "1"), tMult, mkItem(itemNumber, "2"), tExp, mkItem(itemNumber, "3"), tDiv,
 		mkItem(itemNumber, "4"), tFdiv, mkItem(itemNumber, "2"), tMod, mkItem(itemNumber, "3"), tSub, mkItem(itemNumber, "1"), tEOF,
@@ -67,14 +93,63 @@ var lexTests = []lexTest{
 	}},
 
 	{"decimals", "12.3456", []item{mkItem(itemNumber, "12.3456"), tEOF}},
+	{"scientific", "1e3+2.5E-4-1e+2", []item{
+		mkItem(itemNumber, "1e3"), tAdd, mkItem(itemNumber, "2.5E-4"), tSub, mkItem(itemNumber, "1e+2"), tEOF,
+	}},
+	{"hex e", "0xe+0xef+0XE1", []item{
+		mkItem(itemNumber, "0xe"), tAdd, mkItem(itemNumber, "0xef"), tAdd, mkItem(itemNumber, "0XE1"), tEOF,
+	}},
+	{"magnitude suffix", "10k + 1.5ki", []item{
+		mkItem(itemNumber, "10"), mkItem(itemUnit, "k"), tSpace, tAdd, tSpace, mkItem(itemNumber, "1.5"), mkItem(itemUnit, "ki"), tEOF,
+	}},
+	{"hex is not a magnitude suffix", "0xabc", []item{mkItem(itemNumber, "0xabc"), tEOF}},
+	{"units", "100px + 2.5em", []item{
+		mkItem(itemNumber, "100"), mkItem(itemUnit, "px"), tSpace, tAdd, tSpace, mkItem(itemNumber, "2.5"), mkItem(itemUnit, "em"), tEOF,
+	}},
+	{"unit starting with e", "1em+1e", []item{
+		mkItem(itemNumber, "1"), mkItem(itemUnit, "em"), tAdd, mkItem(itemNumber, "1"), mkItem(itemUnit, "e"), tEOF,
+	}},
 
+	{"compound assignment", "$foo **= 2", []item{
+		mkItem(itemVariable, "$foo"), tSpace, mkItem(itemCompoundAssign, "**"), tSpace, mkItem(itemNumber, "2"), tEOF,
+	}},
+	{"compound shift assignment", "$foo<<=1", []item{mkItem(itemVariable, "$foo"), mkItem(itemCompoundAssign, "<<"), mkItem(itemNumber, "1"), tEOF}},
+	{"not compound assignment", "|1|==1 <= 2", []item{
+		mkItem(itemOr, "|"), mkItem(itemNumber, "1"), mkItem(itemOr, "|"), mkItem(itemEq, "=="), mkItem(itemNumber, "1"), tSpace,
+		mkItem(itemLte, "<="), tSpace, mkItem(itemNumber, "2"), tEOF,
+	}},
+
+	{"currency", "€100 + £2.5", []item{mkItem(itemNumber, "100"), tSpace, tAdd, tSpace, mkItem(itemNumber, "2.5"), tEOF}},
+	{"currency is not a variable", "$100", []item{mkItem(itemVariable, "$"), mkItem(itemNumber, "100"), tEOF}},
+	{"currency without a number", "€x", []item{mkItem(itemError, "Unexpected €")}},
+
+	{"comma", "modpow(2,3 ,4)", []item{
+		mkItem(itemFunction, "modpow"), tLpar, mkItem(itemNumber, "2"), mkItem(itemComma, ","), mkItem(itemNumber, "3"), tSpace,
+		mkItem(itemComma, ","), mkItem(itemNumber, "4"), tRpar, tEOF,
+	}},
+	{"constants", "2*pi-sqrt1_2", []item{
+		mkItem(itemNumber, "2"), tMult, mkItem(itemText, "pi"), tSub, mkItem(itemText, "sqrt1_2"), tEOF,
+	}},
+	{"text with digits", "foo2", []item{mkItem(itemText, "foo2"), tEOF}},
 	{"dec", "dec(0755)", []item{mkItem(itemDec, "dec"), tLpar, mkItem(itemNumber, "0755"), tRpar, tEOF}},
 	{"bin", "bin(1+2)", []item{mkItem(itemBin, "bin"), tLpar, mkItem(itemNumber, "1"), tAdd, mkItem(itemNumber, "2"), tRpar, tEOF}},
 	{"hex", "hex( -7+b01 )", []item{mkItem(itemHex, "hex"), tLpar, tSpace, tSub, mkItem(itemNumber, "7"), tAdd, mkItem(itemNumber, "b01"), tSpace, tRpar, tEOF}},
 	{"oct", "oct(0x77)", []item{mkItem(itemOct, "oct"), tLpar, mkItem(itemNumber, "0x77"), tRpar, tEOF}},
 
 	{"load", "load(foo)", []item{mkItem(itemLoad, "load"), tLpar, mkItem(itemText, "foo"), tRpar, tEOF}},
+	{"single letter b", "load(b)", []item{mkItem(itemLoad, "load"), tLpar, mkItem(itemText, "b"), tRpar, tEOF}},
 	{"save", "save(bar_name)", []item{mkItem(itemSave, "save"), tLpar, mkItem(itemText, "bar_name"), tRpar, tEOF}},
+	{"string", `save("a\"b")`, []item{mkItem(itemSave, "save"), tLpar, mkItem(itemString, `a"b`), tRpar, tEOF}},
+	{"string escapes", `"\\ \n"`, []item{mkItem(itemString, "\\ \n"), tEOF}},
+	{"unterminated string", `load("foo`, []item{mkItem(itemLoad, "load"), tLpar, mkItem(itemError, "Unterminated string")}},
+	{"unknown escape", `"\t"`, []item{mkItem(itemError, "Unknown escape \\t in string")}},
+	{"comment", "1 # comment + 2", []item{mkItem(itemNumber, "1"), tSpace, tEOF}},
+	{"comment until newline", "# comment\n1", []item{tNl, mkItem(itemNumber, "1"), tEOF}},
+	{"comment in string", `save("a#b")`, []item{mkItem(itemSave, "save"), tLpar, mkItem(itemString, "a#b"), tRpar, tEOF}},
+	{"full-width digits", "１２３", []item{mkItem(itemError, "Unexpected non-ASCII digit １, numbers can only use 0-9")}},
+	{"arabic-indic digits", "1 + ٣", []item{
+		mkItem(itemNumber, "1"), tSpace, tAdd, tSpace, mkItem(itemError, "Unexpected non-ASCII digit ٣, numbers can only use 0-9"),
+	}},
 }
 
 // collect gathers the emitted items into a slice.
@@ -97,6 +172,63 @@ func collect(t *lexTest) (items []item) {
 	return
 }
 
+func TestLexAliases(t *testing.T) {
+	aliases := map[string]string{"×": "*", "÷": "/", "−": "-", "x": "*", "mod": "%", "//": "/"}
+	tests := []lexTest{
+		{"multiply", "2 × 3", []item{mkItem(itemNumber, "2"), tSpace, tMult, tSpace, mkItem(itemNumber, "3"), tEOF}},
+		{"divide", "6÷2", []item{mkItem(itemNumber, "6"), tDiv, mkItem(itemNumber, "2"), tEOF}},
+		{"minus", "−1−2", []item{tSub, mkItem(itemNumber, "1"), tSub, mkItem(itemNumber, "2"), tEOF}},
+		{"words", "0x10 x 3 mod 2", []item{
+			mkItem(itemNumber, "0x10"), tSpace, tMult, tSpace, mkItem(itemNumber, "3"), tSpace, tMod, tSpace, mkItem(itemNumber, "2"), tEOF,
+		}},
+		{"word boundary", "modulo", []item{mkItem(itemText, "modulo"), tEOF}},
+		{"builtins win", "4//2", []item{mkItem(itemNumber, "4"), tFdiv, mkItem(itemNumber, "2"), tEOF}},
+	}
+
+	for _, test := range tests {
+		var items []item
+		_, itemChan := lexWithConfig(test.input, lexConfig{aliases: aliases})
+		for item := range itemChan {
+			items = append(items, item)
+		}
+
+		if !equal(items, test.items, false) {
+			t.Errorf("%s: got\n\t%+v\nexpected\n\t%v", test.name, items, test.items)
+		}
+	}
+}
+
+func TestLexVariablePrefix(t *testing.T) {
+	tests := []lexTest{
+		{"variable", "@foo + 1", []item{mkItem(itemVariable, "$foo"), tSpace, tAdd, tSpace, mkItem(itemNumber, "1"), tEOF}},
+		{"last assigned", "@@", []item{mkItem(itemVariable, "$$"), tEOF}},
+		{"profile", "@base::x", []item{mkItem(itemVariable, "$base::x"), tEOF}},
+		{"dollar is not a prefix", "$foo", []item{mkItem(itemError, "Unexpected $")}},
+	}
+
+	for _, test := range tests {
+		var items []item
+		_, itemChan := lexWithConfig(test.input, lexConfig{variablePrefix: '@'})
+		for item := range itemChan {
+			items = append(items, item)
+		}
+
+		if !equal(items, test.items, false) {
+			t.Errorf("%s: got\n\t%+v\nexpected\n\t%v", test.name, items, test.items)
+		}
+	}
+
+	// Positions cover the variable as written
+	var items []item
+	_, itemChan := lexWithConfig("1+@foo", lexConfig{variablePrefix: '@'})
+	for item := range itemChan {
+		items = append(items, item)
+	}
+	if items[2].pos != 2 || items[2].end != 6 {
+		t.Errorf("got\n\t%s", formatPositions(items))
+	}
+}
+
 func equal(i1, i2 []item, checkPos bool) bool {
 	if len(i1) != len(i2) {
 		return false
@@ -108,13 +240,93 @@ func equal(i1, i2 []item, checkPos bool) bool {
 		if i1[k].val != i2[k].val {
 			return false
 		}
-		if checkPos && i1[k].pos != i2[k].pos {
+		if checkPos && (i1[k].pos != i2[k].pos || i1[k].end != i2[k].end) {
 			return false
 		}
 	}
 	return true
 }
 
+func TestLexPositions(t *testing.T) {
+	tests := []lexTest{
+		{"assignment", "$foo = 1 + 22", []item{
+			mkItemAt(itemVariable, "$foo", 0, 4),
+			mkItemAt(itemSpace, " ", 4, 5),
+			mkItemAt(itemEquals, "=", 5, 6),
+			mkItemAt(itemSpace, " ", 6, 7),
+			mkItemAt(itemNumber, "1", 7, 8),
+			mkItemAt(itemSpace, " ", 8, 9),
+			mkItemAt(itemAdd, "+", 9, 10),
+			mkItemAt(itemSpace, " ", 10, 11),
+			mkItemAt(itemNumber, "22", 11, 13),
+			mkItemAt(itemEOF, "", 13, 13),
+		}},
+		{"wide spaces and functions", "  hex( 0xff  )\t", []item{
+			mkItemAt(itemSpace, " ", 0, 2),
+			mkItemAt(itemHex, "hex", 2, 5),
+			mkItemAt(itemLParen, "(", 5, 6),
+			mkItemAt(itemSpace, " ", 6, 7),
+			mkItemAt(itemNumber, "0xff", 7, 11),
+			mkItemAt(itemSpace, " ", 11, 13),
+			mkItemAt(itemRParen, ")", 13, 14),
+			mkItemAt(itemSpace, " ", 14, 15),
+			mkItemAt(itemEOF, "", 15, 15),
+		}},
+		{"currency", "€1+£22", []item{
+			mkItemAt(itemNumber, "1", 3, 4),
+			mkItemAt(itemAdd, "+", 4, 5),
+			mkItemAt(itemNumber, "22", 7, 9),
+			mkItemAt(itemEOF, "", 9, 9),
+		}},
+		{"units and aliases", "10px×2+0", []item{
+			mkItemAt(itemNumber, "10", 0, 2),
+			mkItemAt(itemUnit, "px", 2, 4),
+			mkItemAt(itemMult, "*", 4, 6),
+			mkItemAt(itemNumber, "2", 6, 7),
+			mkItemAt(itemAdd, "+", 7, 8),
+			mkItemAt(itemNumber, "0", 8, 9),
+			mkItemAt(itemEOF, "", 9, 9),
+		}},
+		{"quoted string", `save("a\"b")`, []item{
+			mkItemAt(itemSave, "save", 0, 4),
+			mkItemAt(itemLParen, "(", 4, 5),
+			mkItemAt(itemString, `a"b`, 5, 11),
+			mkItemAt(itemRParen, ")", 11, 12),
+			mkItemAt(itemEOF, "", 12, 12),
+		}},
+		{"full-width digit", "12３", []item{
+			mkItemAt(itemNumber, "12", 0, 2),
+			mkItemAt(itemError, "Unexpected non-ASCII digit ３, numbers can only use 0-9", 2, 2),
+		}},
+		{"error", "1 ?", []item{
+			mkItemAt(itemNumber, "1", 0, 1),
+			mkItemAt(itemSpace, " ", 1, 2),
+			mkItemAt(itemError, "Unexpected ?", 2, 2),
+		}},
+	}
+
+	for _, test := range tests {
+		var items []item
+		_, itemChan := lexWithConfig(test.input, lexConfig{aliases: DefaultOperatorAliases})
+		for item := range itemChan {
+			items = append(items, item)
+		}
+
+		if !equal(items, test.items, true) {
+			t.Errorf("%s: got\n\t%s\nexpected\n\t%s", test.name, formatPositions(items), formatPositions(test.items))
+		}
+	}
+}
+
+// formatPositions shows the items with their positions to make differences easy to spot
+func formatPositions(items []item) string {
+	parts := []string{}
+	for _, i := range items {
+		parts = append(parts, fmt.Sprintf("%s@%d-%d", i, i.pos, i.end))
+	}
+	return strings.Join(parts, " ")
+}
+
 func TestLex(t *testing.T) {
 	for _, test := range lexTests {
 		// fmt.Printf("Lexing: %#v\n", test.input)
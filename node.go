@@ -2,6 +2,9 @@ package zappaclang
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -38,7 +41,7 @@ const (
 	NodeLParen
 	// NodeRParen is for )
 	NodeRParen
-	// NodeNumber is for 123, 0.123, 0xff, b001, and 0755
+	// NodeNumber is for 123, 0.123, 1e3, 0xff, b001, and 0755
 	NodeNumber
 	// NodeVariable is for $foo
 	NodeVariable
@@ -78,9 +81,85 @@ const (
 	NodeLoad
 	// NodeClear is for clear()
 	NodeClear
+	// NodeFunction is for other functions like float_bits()
+	NodeFunction
+	// NodeInlineAssign is for $foo := within expressions
+	NodeInlineAssign
+	// NodeLt is for <
+	NodeLt
+	// NodeLte is for <=
+	NodeLte
+	// NodeGt is for >
+	NodeGt
+	// NodeGte is for >=
+	NodeGte
+	// NodeEq is for ==
+	NodeEq
+	// NodeNe is for !=
+	NodeNe
+	// NodeComma is for , between function arguments
+	NodeComma
+	// NodeWhere is for where()
+	NodeWhere
+	// NodeHelp is for help()
+	NodeHelp
+	// NodeInspect is for inspect()
+	NodeInspect
+	// NodeString is for quoted strings as function arguments, e.g. "ff" in convert("ff", 16, 10)
+	NodeString
+	// New node types need to be added to nodeTypeNames too
 )
 
-//go:generate stringer -type=NodeType
+// nodeTypeNames are the names of the node types, written out instead of generated so nodes built by hand with
+// types that don't exist can be named safely
+var nodeTypeNames = [...]string{
+	NodeEOF:            "NodeEOF",
+	NodeParsingStopped: "NodeParsingStopped",
+	NodeAssign:         "NodeAssign",
+	NodeLParen:         "NodeLParen",
+	NodeRParen:         "NodeRParen",
+	NodeNumber:         "NodeNumber",
+	NodeVariable:       "NodeVariable",
+	NodeAdd:            "NodeAdd",
+	NodeSub:            "NodeSub",
+	NodeMult:           "NodeMult",
+	NodeExp:            "NodeExp",
+	NodeDiv:            "NodeDiv",
+	NodeFdiv:           "NodeFdiv",
+	NodeAnd:            "NodeAnd",
+	NodeOr:             "NodeOr",
+	NodeXor:            "NodeXor",
+	NodeInv:            "NodeInv",
+	NodeMod:            "NodeMod",
+	NodeLShift:         "NodeLShift",
+	NodeRShift:         "NodeRShift",
+	NodeAbs:            "NodeAbs",
+	NodeSetOutput:      "NodeSetOutput",
+	NodeSave:           "NodeSave",
+	NodeLoad:           "NodeLoad",
+	NodeClear:          "NodeClear",
+	NodeFunction:       "NodeFunction",
+	NodeInlineAssign:   "NodeInlineAssign",
+	NodeLt:             "NodeLt",
+	NodeLte:            "NodeLte",
+	NodeGt:             "NodeGt",
+	NodeGte:            "NodeGte",
+	NodeEq:             "NodeEq",
+	NodeNe:             "NodeNe",
+	NodeComma:          "NodeComma",
+	NodeWhere:          "NodeWhere",
+	NodeHelp:           "NodeHelp",
+	NodeInspect:        "NodeInspect",
+	NodeString:         "NodeString",
+}
+
+// String gives the name of the node type, or "unknown node" for types that don't exist
+func (t NodeType) String() string {
+	if t < 0 || int(t) >= len(nodeTypeNames) || nodeTypeNames[t] == "" {
+		return "unknown node"
+	}
+	return nodeTypeNames[t]
+}
 
 // OperatorNodes are operators between values
 var OperatorNodes = []NodeType{
@@ -97,6 +176,22 @@ var OperatorNodes = []NodeType{
 	NodeMod,
 	NodeLShift,
 	NodeRShift,
+	NodeLt,
+	NodeLte,
+	NodeGt,
+	NodeGte,
+	NodeEq,
+	NodeNe,
+}
+
+// ComparisonNodes are the operators comparing values, which can be chained like 1 < $foo < 10
+var ComparisonNodes = []NodeType{
+	NodeLt,
+	NodeLte,
+	NodeGt,
+	NodeGte,
+	NodeEq,
+	NodeNe,
 }
 
 var operatorMap = map[string]NodeType{
@@ -113,6 +208,12 @@ var operatorMap = map[string]NodeType{
 	"%":  NodeMod,
 	"<<": NodeLShift,
 	">>": NodeRShift,
+	"<":  NodeLt,
+	"<=": NodeLte,
+	">":  NodeGt,
+	">=": NodeGte,
+	"==": NodeEq,
+	"!=": NodeNe,
 }
 
 var diskOperationMap = map[string]NodeType{
@@ -134,6 +235,180 @@ var FunctionNodes = []NodeType{
 	NodeSave,
 	NodeLoad,
 	NodeClear,
+	NodeFunction,
+	NodeWhere,
+	NodeHelp,
+	NodeInspect,
+}
+
+// commandName gives the name a function or command node is called with, e.g. abs or save, or "" for other nodes
+func commandName(node Node) string {
+	switch n := node.(type) {
+	case FunctionNode:
+		return n.Name
+	case AbsNode:
+		return "abs"
+	case SetOutputNode:
+		return strings.ToLower(n.Output.String())
+	case DiskOperationNode:
+		return n.Operation
+	case ClearNode:
+		return "clear"
+	case WhereNode:
+		return "where"
+	case HelpNode:
+		return "help"
+	case InspectNode:
+		return "inspect"
+	}
+	return ""
+}
+
+// TrimTerminators gives the parsed nodes without the trailing EOF and ParsingStopped nodes
+func TrimTerminators(nodes []Node) []Node {
+	end := len(nodes)
+	for end > 0 && IsNodeType(nodes[end-1], []NodeType{NodeEOF, NodeParsingStopped}) {
+		end--
+	}
+	return nodes[:end]
+}
+
+// OperationCount counts the calculations in parsed nodes, i.e. the operators and functions like abs(), e.g. to
+// limit the complexity of expressions
+func OperationCount(nodes []Node) int {
+	count := 0
+	for _, node := range nodes {
+		if IsNodeType(node, OperatorNodes) || IsNodeType(node, []NodeType{NodeAbs, NodeFunction}) {
+			count++
+		}
+	}
+	return count
+}
+
+// Dependencies is what a calculation reads from and changes in the state, see FindDependencies
+type Dependencies struct {
+	// Reads are the variables the calculation uses, sorted, e.g. [$foo] for $foo + 1
+	Reads []string
+	// Writes are the variables the calculation assigns to, sorted, e.g. [$foo] for $foo = 1
+	Writes []string
+	// Clears is set for clear(), which removes all the variables
+	Clears bool
+	// Disk is set when profiles are saved or loaded, including reading $profile::name references. Loading can change
+	// any of the variables.
+	Disk bool
+}
+
+// FindDependencies finds what the parsed calculation reads from and changes in the state without executing it, e.g.
+// to know when a cached result needs to be calculated again
+func FindDependencies(nodes []Node) Dependencies {
+	deps := Dependencies{Reads: []string{}, Writes: []string{}}
+	reads := map[string]bool{}
+	writes := map[string]bool{}
+
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case VariableNode:
+			reads[n.Name] = true
+			if strings.Contains(n.Name, profileSeparator) {
+				deps.Disk = true
+			}
+		case AssignNode:
+			writes[n.Target] = true
+		case InlineAssignNode:
+			writes[n.Target] = true
+		case ClearNode:
+			deps.Clears = true
+		case DiskOperationNode:
+			deps.Disk = true
+		}
+	}
+
+	for name := range reads {
+		deps.Reads = append(deps.Reads, name)
+	}
+	for name := range writes {
+		deps.Writes = append(deps.Writes, name)
+	}
+	sort.Strings(deps.Reads)
+	sort.Strings(deps.Writes)
+
+	return deps
+}
+
+// ResultKind is the kind of result an expression gives, see DetectResultKind
+type ResultKind int
+
+const (
+	// ResultInteger is a whole number
+	ResultInteger ResultKind = iota
+	// ResultFloat is a number that can have a fractional part
+	ResultFloat
+	// ResultConversion is a conversion like hex(255)
+	ResultConversion
+	// ResultCommand is a command like save(name) or clear() that gives a message instead of a number
+	ResultCommand
+)
+
+//go:generate stringer -type=ResultKind
+
+// commandNodes are the nodes that start commands with a message as the result
+var commandNodes = []NodeType{NodeClear, NodeWhere, NodeHelp, NodeInspect, NodeSave, NodeLoad}
+
+// floatFunctions can give fractions even with integer arguments
+var floatFunctions = map[string]bool{
+	"logn":      true,
+	"pctchange": true,
+}
+
+// DetectResultKind tells from parsed nodes if the result will be an integer or can have a fractional part, or if
+// it's a conversion or command, without evaluating anything. Variables can hold fractions, so they count as floats.
+func DetectResultKind(nodes []Node) (ResultKind, error) {
+	if len(nodes) > 0 && nodes[len(nodes)-1].Type() == NodeParsingStopped {
+		return ResultInteger, fmt.Errorf("can't detect the result kind, parsing stopped at pos %d", nodes[len(nodes)-1].Position())
+	}
+
+	nodes = TrimTerminators(nodes)
+	if len(nodes) == 0 {
+		return ResultInteger, ErrorNoValue
+	}
+
+	if IsNodeType(nodes[0], commandNodes) {
+		return ResultCommand, nil
+	}
+	if nodes[0].Type() == NodeSetOutput {
+		return ResultConversion, nil
+	}
+
+	for i, node := range nodes {
+		switch node.Type() {
+		case NodeDiv, NodeVariable:
+			return ResultFloat, nil
+		case NodeNumber:
+			num, _ := node.(NumberNode)
+			r, err := num.toRat()
+			if err != nil {
+				return ResultInteger, err
+			}
+			if !r.IsInt() {
+				return ResultFloat, nil
+			}
+		case NodeExp:
+			// Only non-negative integer exponents keep integers whole
+			if i+1 >= len(nodes) || nodes[i+1].Type() != NodeNumber {
+				return ResultFloat, nil
+			}
+			exponent, _ := nodes[i+1].(NumberNode)
+			if r, err := exponent.toRat(); err != nil || r.Sign() < 0 {
+				return ResultFloat, nil
+			}
+		case NodeFunction:
+			if floatFunctions[node.String()] {
+				return ResultFloat, nil
+			}
+		}
+	}
+
+	return ResultInteger, nil
 }
 
 // Nodes that can be prefixes to most values
@@ -141,6 +416,8 @@ var prefixNodes = []NodeType{
 	NodeLParen,
 	NodeSetOutput,
 	NodeAssign,
+	NodeInlineAssign,
+	NodeComma,
 }
 
 // ValueNodes are values that can be evaluated as values
@@ -166,6 +443,7 @@ const (
 //go:generate stringer -type=NumberSystem
 
 func parseNumberSystem(number string) NumberSystem {
+	number = strings.TrimPrefix(number, "-")
 	if len(number) > 0 {
 		if number[0] == 'b' || number[0] == 'B' {
 			return Bin
@@ -178,7 +456,7 @@ func parseNumberSystem(number string) NumberSystem {
 			if number[1] == 'x' || number[1] == 'X' {
 				return Hex
 			}
-			if number[1] == '.' {
+			if number[1] == '.' || number[1] == 'e' || number[1] == 'E' {
 				return Dec
 			}
 			return Oct
@@ -187,6 +465,90 @@ func parseNumberSystem(number string) NumberSystem {
 	return Dec
 }
 
+var numberBases = map[NumberSystem]int{
+	Dec: 10,
+	Hex: 16,
+	Bin: 2,
+	Oct: 8,
+}
+
+var numberPrefixes = map[NumberSystem]string{
+	Dec: "",
+	Hex: "0x",
+	Bin: "b",
+	Oct: "0",
+}
+
+// CanonicalizeNumber normalizes a number literal to the form used in zappac lang, e.g. 0XFF -> 0xff,
+// B0001 -> b1, and 0o17 -> 017
+func CanonicalizeNumber(number string) (string, error) {
+	s := strings.ToLower(strings.TrimSpace(number))
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+
+	// Accept the 0o prefix used by many other languages
+	if strings.HasPrefix(s, "0o") {
+		s = "0" + s[2:]
+	}
+
+	system := parseNumberSystem(s)
+	if system == Dec {
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "", fmt.Errorf("invalid number %s", number)
+		}
+		return sign + s, nil
+	}
+
+	prefix := numberPrefixes[system]
+	value, ok := new(big.Int).SetString(s[len(prefix):], numberBases[system])
+	if !ok {
+		return "", fmt.Errorf("invalid number %s", number)
+	}
+
+	return sign + prefix + value.Text(numberBases[system]), nil
+}
+
+// convertLiteral converts a number literal to the given number system
+func convertLiteral(number string, system NumberSystem) (string, error) {
+	canonical, err := CanonicalizeNumber(number)
+	if err != nil {
+		return "", err
+	}
+
+	num := newNumber(-1, canonical, parseNumberSystem(canonical))
+	if system != Dec {
+		if _, err := num.toInt64(); err != nil {
+			return "", &OperationError{"conversion to " + system.String(), number, "requires an integer"}
+		}
+	}
+
+	return convertNumber(num, system)
+}
+
+// ToDec converts any number literal to decimal, e.g. 0xff -> 255
+func ToDec(number string) (string, error) {
+	return convertLiteral(number, Dec)
+}
+
+// ToHex converts any integer number literal to hexadecimal, e.g. 255 -> 0xff
+func ToHex(number string) (string, error) {
+	return convertLiteral(number, Hex)
+}
+
+// ToBin converts any integer number literal to binary, e.g. 5 -> b101
+func ToBin(number string) (string, error) {
+	return convertLiteral(number, Bin)
+}
+
+// ToOct converts any integer number literal to octal, e.g. 8 -> 010
+func ToOct(number string) (string, error) {
+	return convertLiteral(number, Oct)
+}
+
 // AssignNode $foo =
 type AssignNode struct {
 	NodeType
@@ -206,6 +568,25 @@ func newAssign(pos Pos, target string) AssignNode {
 	}
 }
 
+// InlineAssignNode $foo :=
+type InlineAssignNode struct {
+	NodeType
+	Pos
+	Target string
+}
+
+func (ian InlineAssignNode) String() string {
+	return fmt.Sprintf("%s :=", ian.Target)
+}
+
+func newInlineAssign(pos Pos, target string) InlineAssignNode {
+	return InlineAssignNode{
+		NodeType: NodeInlineAssign,
+		Pos:      pos,
+		Target:   target,
+	}
+}
+
 // VariableNode $foo
 type VariableNode struct {
 	NodeType
@@ -244,29 +625,172 @@ func newSetOutput(pos Pos, output string) SetOutputNode {
 	}
 }
 
-// NumberNode 123, 0.123, 0xff, b001, and 0755
+// NumberNode 123, 0.123, 0xff, b001, and 0755, optionally with a unit like 100px
 type NumberNode struct {
 	NodeType
 	Pos
 	Value  string
 	System NumberSystem
+	// Unit is carried through calculations, but otherwise ignored
+	Unit string `yaml:"unit,omitempty"`
+	// KeepSystem shows a variable used alone in its own number system instead of decimal, set when it was assigned
+	// through a conversion like $foo = hex(255)
+	KeepSystem bool `yaml:"keepSystem,omitempty"`
+	// Base shows an integer result in a base without a number system, e.g. 36#73 for 255 in base 36. Set by convert(),
+	// calculations with the number give plain decimal results.
+	Base int `yaml:"-"`
 }
 
 func (nn NumberNode) String() string {
-	return nn.Value
+	return nn.Value + nn.Unit
 }
 
 func (nn NumberNode) toFloat64() (float64, error) {
-	if nn.System == Dec {
+	if nn.System == Dec && strings.Contains(nn.Value, "/") {
+		// Fraction from FractionMode
+		r, err := nn.toRat()
+		if err != nil {
+			return 0, err
+		}
+		f64, _ := r.Float64()
+		return f64, nil
+	} else if nn.System == Dec {
 		f64, err := strconv.ParseFloat(nn.Value, 64)
 		return f64, err
-	} else if nn.System == Oct || nn.System == Hex {
-		i64, err := strconv.ParseInt(nn.Value, 0, 64)
-		return float64(i64), err
-	} else { // Bin
-		i64, err := strconv.ParseInt("0"+nn.Value, 0, 64)
-		return float64(i64), err
 	}
+
+	// Any size, e.g. float_bits(-1.0) gives 0xbff0000000000000 which doesn't fit an int64
+	i, ok := nn.toBigInt()
+	if !ok {
+		return 0, fmt.Errorf("invalid number %s", nn.Value)
+	}
+	f64, _ := new(big.Float).SetInt(i).Float64()
+	return f64, nil
+}
+
+// toRat reads the number as an exact fraction
+func (nn NumberNode) toRat() (*big.Rat, error) {
+	if nn.System != Dec {
+		i, ok := nn.toBigInt()
+		if !ok {
+			return nil, fmt.Errorf("invalid number %s", nn.Value)
+		}
+		return new(big.Rat).SetInt(i), nil
+	}
+
+	r, ok := new(big.Rat).SetString(nn.Value)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %s", nn.Value)
+	}
+	return r, nil
+}
+
+// binaryLiteral converts b101 style binary numbers to the 0b101 style strconv understands
+func binaryLiteral(value string) string {
+	if strings.HasPrefix(value, "-") {
+		return "-0" + value[1:]
+	}
+	return "0" + value
+}
+
+// AtWidth renders the integer as it would be stored in the given number of bits, in its current number system.
+// Decimal numbers are shown as signed, other systems show the two's complement bits, e.g. -1 at 8 bits is 0xff.
+func (nn NumberNode) AtWidth(bits int) (string, error) {
+	if bits < 1 || bits > 64 {
+		return "", fmt.Errorf("bit width must be between 1 and 64, got %d", bits)
+	}
+
+	i64, err := nn.toInt64()
+	if err != nil {
+		return "", fmt.Errorf("%s is not an integer, can't show it at %d bits", nn.Value, bits)
+	}
+
+	u64 := truncateToWidth(i64, bits)
+	if nn.System == Dec {
+		// Sign extend from the top bit of the width
+		shift := 64 - bits
+		return strconv.FormatInt(int64(u64<<shift)>>shift, 10), nil
+	}
+
+	return numberPrefixes[nn.System] + strconv.FormatUint(u64, numberBases[nn.System]), nil
+}
+
+// Describe shows the number in all number systems with their labels, e.g. 255 (Dec) = 0xff (Hex) = 0377 (Oct) =
+// b11111111 (Bin). Numbers that are not integers are only shown in decimal.
+func (nn NumberNode) Describe() string {
+	bases, err := allBases(nn)
+	if err != nil {
+		return nn.String()
+	}
+
+	parts := []string{}
+	for _, system := range []NumberSystem{Dec, Hex, Oct, Bin} {
+		if value, ok := bases[system]; ok {
+			parts = append(parts, fmt.Sprintf("%s (%s)", value, system))
+		}
+	}
+
+	return strings.Join(parts, " = ")
+}
+
+// toBigInt reads the number as an exact integer of any size, ok is false when it has a fractional part
+func (nn NumberNode) toBigInt() (*big.Int, bool) {
+	if nn.System != Dec {
+		literal := nn.Value
+		if nn.System == Bin {
+			literal = binaryLiteral(literal)
+		}
+		return new(big.Int).SetString(literal, 0)
+	}
+
+	r, ok := new(big.Rat).SetString(nn.Value)
+	if !ok || !r.IsInt() {
+		return nil, false
+	}
+	return r.Num(), true
+}
+
+// formatInteger formats the integer in the number system, e.g. -0xff
+func formatInteger(i *big.Int, system NumberSystem) string {
+	sign := ""
+	if i.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + numberPrefixes[system] + new(big.Int).Abs(i).Text(numberBases[system])
+}
+
+// formatBase formats the integer in any base from 2 to 36 tagged with the base, e.g. 36#73 or -2#101
+func formatBase(i *big.Int, base int) string {
+	sign := ""
+	if i.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + strconv.Itoa(base) + "#" + new(big.Int).Abs(i).Text(base)
+}
+
+// toInt64 reads the number as an integer, failing for anything with a fractional part
+func (nn NumberNode) toInt64() (int64, error) {
+	if nn.System == Bin {
+		return strconv.ParseInt(binaryLiteral(nn.Value), 0, 64)
+	} else if nn.System != Dec {
+		return strconv.ParseInt(nn.Value, 0, 64)
+	}
+
+	i64, err := strconv.ParseInt(nn.Value, 10, 64)
+	if err == nil {
+		return i64, nil
+	}
+
+	f64, err := nn.toFloat64()
+	if err != nil {
+		return 0, err
+	}
+
+	if f64 != math.Trunc(f64) {
+		return 0, fmt.Errorf("%s is not an integer", nn.Value)
+	}
+
+	return int64(f64), nil
 }
 
 func newNumber(pos Pos, value string, system NumberSystem) NumberNode {
@@ -278,6 +802,10 @@ func newNumber(pos Pos, value string, system NumberSystem) NumberNode {
 	}
 }
 
+func newFloatNumber(value float64) NumberNode {
+	return newNumber(-1, strconv.FormatFloat(value, 'f', -1, 64), Dec)
+}
+
 // OperatorNode + - * ** / // & | ^ ~ % << >>
 type OperatorNode struct {
 	NodeType
@@ -306,7 +834,53 @@ type DiskOperationNode struct {
 }
 
 func (don DiskOperationNode) String() string {
-	return fmt.Sprintf("%s(%s)", don.Operation, don.Profile)
+	profile := don.Profile
+	if strings.Trim(profile, letters+"_") != "" || !strings.ContainsAny(profile[:1], letters) {
+		profile = quoteString(profile)
+	}
+	return fmt.Sprintf("%s(%s)", don.Operation, profile)
+}
+
+// quoteString quotes the string so lexString reads it back as is
+func quoteString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// InspectNode inspect()
+type InspectNode struct {
+	NodeType
+	Pos
+}
+
+func (i InspectNode) String() string {
+	return "inspect()"
+}
+
+func newInspect(pos Pos) InspectNode {
+	return InspectNode{
+		NodeType: NodeInspect,
+		Pos:      pos,
+	}
+}
+
+// StringNode "ff", only used as a function argument
+type StringNode struct {
+	NodeType
+	Pos
+	Value string
+}
+
+func (sn StringNode) String() string {
+	return quoteString(sn.Value)
+}
+
+func newString(pos Pos, value string) StringNode {
+	return StringNode{
+		NodeType: NodeString,
+		Pos:      pos,
+		Value:    value,
+	}
 }
 
 func newDiskOperation(pos Pos, op string, profile string) DiskOperationNode {
@@ -335,6 +909,23 @@ func newLParen(pos Pos) LParenNode {
 	}
 }
 
+// CommaNode ,
+type CommaNode struct {
+	NodeType
+	Pos
+}
+
+func (cn CommaNode) String() string {
+	return ","
+}
+
+func newComma(pos Pos) CommaNode {
+	return CommaNode{
+		NodeType: NodeComma,
+		Pos:      pos,
+	}
+}
+
 // RParenNode )
 type RParenNode struct {
 	NodeType
@@ -369,6 +960,25 @@ func newAbs(pos Pos) AbsNode {
 	}
 }
 
+// FunctionNode float_bits() and other functions
+type FunctionNode struct {
+	NodeType
+	Pos
+	Name string
+}
+
+func (fn FunctionNode) String() string {
+	return fn.Name
+}
+
+func newFunction(pos Pos, name string) FunctionNode {
+	return FunctionNode{
+		NodeType: NodeFunction,
+		Pos:      pos,
+		Name:     name,
+	}
+}
+
 // EOFNode EOF
 type EOFNode struct {
 	NodeType
@@ -419,3 +1029,37 @@ func newClear(pos Pos) ClearNode {
 		Pos:      pos,
 	}
 }
+
+// WhereNode where()
+type WhereNode struct {
+	NodeType
+	Pos
+}
+
+func (w WhereNode) String() string {
+	return "where()"
+}
+
+func newWhere(pos Pos) WhereNode {
+	return WhereNode{
+		NodeType: NodeWhere,
+		Pos:      pos,
+	}
+}
+
+// HelpNode help()
+type HelpNode struct {
+	NodeType
+	Pos
+}
+
+func (h HelpNode) String() string {
+	return "help()"
+}
+
+func newHelp(pos Pos) HelpNode {
+	return HelpNode{
+		NodeType: NodeHelp,
+		Pos:      pos,
+	}
+}
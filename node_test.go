@@ -0,0 +1,338 @@
+package zappaclang
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type canonicalizeTest struct {
+	input    string
+	expected string
+}
+
+var canonicalizeTests = []canonicalizeTest{
+	{"0XFF", "0xff"},
+	{"0x00Ab", "0xab"},
+	{"B0001", "b1"},
+	{"b0", "b0"},
+	{"0o17", "017"},
+	{"0O0017", "017"},
+	{"0755", "0755"},
+	{"-0xFF", "-0xff"},
+	{"123", "123"},
+	{"1.50", "1.50"},
+	{"0.5", "0.5"},
+}
+
+func TestCanonicalizeNumber(t *testing.T) {
+	for _, test := range canonicalizeTests {
+		result, err := CanonicalizeNumber(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v\nexpected\n\t%s", test.input, err, test.expected)
+			continue
+		}
+
+		if result != test.expected {
+			t.Errorf("%s: got\n\t%s\nexpected\n\t%s", test.input, result, test.expected)
+		}
+	}
+
+	for _, invalid := range []string{"0xfg", "b102", "0o8", "12a", ""} {
+		if result, err := CanonicalizeNumber(invalid); err == nil {
+			t.Errorf("%s: expected an error, got %s", invalid, result)
+		}
+	}
+}
+
+type conversionTest struct {
+	convert  func(string) (string, error)
+	input    string
+	expected string
+}
+
+var conversionTests = []conversionTest{
+	{ToDec, "0xff", "255"},
+	{ToDec, "b101", "5"},
+	{ToDec, "0755", "493"},
+	{ToDec, "1.5", "1.5"},
+	{ToHex, "255", "0xff"},
+	{ToHex, "0XFF", "0xff"},
+	{ToHex, "0o17", "0xf"},
+	{ToBin, "0xa", "b1010"},
+	{ToBin, "8", "b1000"},
+	{ToOct, "8", "010"},
+	{ToOct, "b111", "07"},
+}
+
+func TestConversions(t *testing.T) {
+	for _, test := range conversionTests {
+		result, err := test.convert(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v\nexpected\n\t%s", test.input, err, test.expected)
+			continue
+		}
+
+		if result != test.expected {
+			t.Errorf("%s: got\n\t%s\nexpected\n\t%s", test.input, result, test.expected)
+		}
+	}
+
+	for _, convert := range []func(string) (string, error){ToHex, ToBin, ToOct} {
+		if result, err := convert("1.5"); err == nil {
+			t.Errorf("1.5: expected an error, got %s", result)
+		}
+	}
+
+	if result, err := ToDec("0xfg"); err == nil {
+		t.Errorf("0xfg: expected an error, got %s", result)
+	}
+}
+
+func TestNegativeNumberSystems(t *testing.T) {
+	tests := []struct {
+		input    string
+		system   NumberSystem
+		expected float64
+	}{
+		{"-0xff", Hex, -255},
+		{"-0755", Oct, -493},
+		{"-b101", Bin, -5},
+		{"-0", Dec, 0},
+		{"-0.5", Dec, -0.5},
+		{"-12", Dec, -12},
+	}
+
+	for _, test := range tests {
+		system := parseNumberSystem(test.input)
+		if system != test.system {
+			t.Errorf("%s: got\n\t%s\nexpected\n\t%s", test.input, system, test.system)
+		}
+
+		f64, err := newNumber(-1, test.input, system).toFloat64()
+		if err != nil || f64 != test.expected {
+			t.Errorf("%s: got\n\t%v, %v\nexpected\n\t%v", test.input, f64, err, test.expected)
+		}
+	}
+}
+
+func TestAtWidth(t *testing.T) {
+	tests := []struct {
+		number   NumberNode
+		bits     int
+		expected string
+	}{
+		{newNumber(-1, "-0x1", Hex), 8, "0xff"},
+		{newNumber(-1, "-0x1", Hex), 16, "0xffff"},
+		{newNumber(-1, "-0x1", Hex), 32, "0xffffffff"},
+		{newNumber(-1, "-b1", Bin), 8, "b11111111"},
+		{newNumber(-1, "-01", Oct), 8, "0377"},
+		{newNumber(-1, "-1", Dec), 8, "-1"},
+		{newNumber(-1, "-1", Dec), 16, "-1"},
+		{newNumber(-1, "-1", Dec), 32, "-1"},
+		{newNumber(-1, "255", Dec), 8, "-1"},
+		{newNumber(-1, "255", Dec), 16, "255"},
+		{newNumber(-1, "0x1ff", Hex), 8, "0xff"},
+		{newNumber(-1, "-1", Dec), 64, "-1"},
+		{newNumber(-1, "1.5", Dec), 8, "1.5 is not an integer, can't show it at 8 bits"},
+		{newNumber(-1, "1", Dec), 0, "bit width must be between 1 and 64, got 0"},
+	}
+
+	for _, test := range tests {
+		result, err := test.number.AtWidth(test.bits)
+		if err != nil {
+			result = err.Error()
+		}
+		if result != test.expected {
+			t.Errorf("%s at %d bits: got\n\t%s\nexpected\n\t%s", test.number.Value, test.bits, result, test.expected)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		number   NumberNode
+		expected string
+	}{
+		{newNumber(-1, "255", Dec), "255 (Dec) = 0xff (Hex) = 0377 (Oct) = b11111111 (Bin)"},
+		{newNumber(-1, "0x10", Hex), "16 (Dec) = 0x10 (Hex) = 020 (Oct) = b10000 (Bin)"},
+		{newNumber(-1, "1.5", Dec), "1.5 (Dec)"},
+		{newNumber(-1, "0x7fffffffffffffff", Hex), "9223372036854775807 (Dec) = 0x7fffffffffffffff (Hex) = 0777777777777777777777 (Oct) = b" + strings.Repeat("1", 63) + " (Bin)"},
+		{newNumber(-1, "-9223372036854775808", Dec), "-9223372036854775808 (Dec) = -0x8000000000000000 (Hex) = -01000000000000000000000 (Oct) = -b1" + strings.Repeat("0", 63) + " (Bin)"},
+		{newNumber(-1, "0xffffffffffffffff", Hex), "18446744073709551615 (Dec) = 0xffffffffffffffff (Hex) = 01777777777777777777777 (Oct) = b" + strings.Repeat("1", 64) + " (Bin)"},
+	}
+
+	for _, test := range tests {
+		result := test.number.Describe()
+		if result != test.expected {
+			t.Errorf("%s: got\n\t%s\nexpected\n\t%s", test.number.Value, result, test.expected)
+		}
+	}
+}
+
+func TestNodeTypeString(t *testing.T) {
+	for typ, name := range nodeTypeNames {
+		if name == "" {
+			t.Errorf("NodeType %d has no name", typ)
+		}
+	}
+
+	if NodeWhere.String() != "NodeWhere" {
+		t.Errorf("got\n\t%s\nexpected\n\tNodeWhere", NodeWhere)
+	}
+
+	invalid := ClearNode{NodeType: NodeType(len(nodeTypeNames)), Pos: 0}
+	for _, typ := range []NodeType{invalid.Type(), NodeType(-1)} {
+		if result := fmt.Sprintf("unexpected %s", typ); result != "unexpected unknown node" {
+			t.Errorf("got\n\t%s\nexpected\n\tunexpected unknown node", result)
+		}
+	}
+}
+
+func TestOperationCount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", 0},
+		{"5", 0},
+		{"-5", 0},
+		{"$foo = 1 + 2", 1},
+		{"(1 + 2) * 3 - -4", 3},
+		{"hex(abs(-2) ** 2)", 2},
+		{"modpow(2, 10, 100) < 5 < 10", 3},
+		{"clear()", 0},
+	}
+
+	for _, test := range tests {
+		nodes, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		if count := OperationCount(nodes); count != test.expected {
+			t.Errorf("%s: got\n\t%d\nexpected\n\t%d", test.input, count, test.expected)
+		}
+	}
+}
+
+func TestTrimTerminators(t *testing.T) {
+	nodes, err := Parse("$foo = 1 + 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trimmed := TrimTerminators(nodes)
+	if len(trimmed) != len(nodes)-1 || fmt.Sprint(trimmed) != "[$foo = 1 + 2]" {
+		t.Errorf("got\n\t%v\nexpected\n\t[$foo = 1 + 2]", trimmed)
+	}
+
+	// Parsing errors end with ParsingStopped instead
+	nodes, err = Parse("1 +")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if trimmed := TrimTerminators(nodes); fmt.Sprint(trimmed) != "[1 +]" {
+		t.Errorf("got\n\t%v\nexpected\n\t[1 +]", trimmed)
+	}
+
+	if trimmed := TrimTerminators([]Node{newEOF(0)}); len(trimmed) != 0 {
+		t.Errorf("got\n\t%v\nexpected no nodes", trimmed)
+	}
+	if trimmed := TrimTerminators(nil); len(trimmed) != 0 {
+		t.Errorf("got\n\t%v\nexpected no nodes", trimmed)
+	}
+}
+
+func TestDetectResultKind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ResultKind
+	}{
+		{"1 + 2 * 3", ResultInteger},
+		{"0xff // 2 % 3", ResultInteger},
+		{"2 ** 10", ResultInteger},
+		{"1e3 - 1", ResultInteger},
+		{"modpow(2, 10, 100)", ResultInteger},
+		{"1 < 2", ResultInteger},
+		{"10 / 5", ResultFloat},
+		{"1.5 + 1", ResultFloat},
+		{"2 ** -1", ResultFloat},
+		{"2 ** (1 + 1)", ResultFloat},
+		{"$foo + 1", ResultFloat},
+		{"logn(8, 2)", ResultFloat},
+		{"$foo = 1 + 1", ResultInteger},
+		{"hex(255)", ResultConversion},
+		{"dec(1 / 2)", ResultConversion},
+		{"clear()", ResultCommand},
+		{"save(foo)", ResultCommand},
+		{"help()", ResultCommand},
+	}
+
+	for _, test := range tests {
+		nodes, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		kind, err := DetectResultKind(nodes)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+		} else if kind != test.expected {
+			t.Errorf("%s: got\n\t%s\nexpected\n\t%s", test.input, kind, test.expected)
+		}
+	}
+
+	nodes, _ := Parse("1 +")
+	if _, err := DetectResultKind(nodes); err == nil {
+		t.Errorf("expected an error for input that failed to parse")
+	}
+
+	nodes, _ = Parse("")
+	if _, err := DetectResultKind(nodes); !errors.Is(err, ErrorNoValue) {
+		t.Errorf("expected ErrorNoValue for empty input, got %v", err)
+	}
+}
+
+func TestFindDependencies(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Dependencies
+	}{
+		{"1 + 2 * 3", Dependencies{Reads: []string{}, Writes: []string{}}},
+		{"$foo = 5", Dependencies{Reads: []string{}, Writes: []string{"$foo"}}},
+		{"$foo = $bar * $baz + $bar", Dependencies{Reads: []string{"$bar", "$baz"}, Writes: []string{"$foo"}}},
+		{"$foo += 1", Dependencies{Reads: []string{"$foo"}, Writes: []string{"$foo"}}},
+		{"$$ * 2", Dependencies{Reads: []string{"$$"}, Writes: []string{}}},
+		{"save(foo)", Dependencies{Reads: []string{}, Writes: []string{}, Disk: true}},
+		{"load(foo)", Dependencies{Reads: []string{}, Writes: []string{}, Disk: true}},
+		{"$base::x + 1", Dependencies{Reads: []string{"$base::x"}, Writes: []string{}, Disk: true}},
+		{"clear()", Dependencies{Reads: []string{}, Writes: []string{}, Clears: true}},
+	}
+
+	for _, test := range tests {
+		nodes, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.input, err)
+			continue
+		}
+
+		deps := FindDependencies(nodes)
+		if fmt.Sprintf("%+v", deps) != fmt.Sprintf("%+v", test.expected) {
+			t.Errorf("%s: got\n\t%+v\nexpected\n\t%+v", test.input, deps, test.expected)
+		}
+	}
+
+	p := NewParser()
+	p.InlineAssignment = true
+	nodes, err := p.Parse("($foo := 2) * $foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deps := FindDependencies(nodes); fmt.Sprint(deps.Reads, deps.Writes) != "[$foo] [$foo]" {
+		t.Errorf("got\n\t%+v", deps)
+	}
+}
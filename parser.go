@@ -3,28 +3,100 @@ package zappaclang
 import (
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 )
 
-type parser struct {
+// Parser turns zappac lang strings into nodes, and can be reused for multiple inputs
+type Parser struct {
+	// InlineAssignment enables $foo := assignments within expressions, e.g. ($foo := 5) + $foo
+	InlineAssignment bool
+	// OperatorAliases maps alternative spellings to operators, e.g. DefaultOperatorAliases or {"mod": "%"}
+	OperatorAliases map[string]string
+	// LeadingOperator allows starting the input with an operator, e.g. + 5, to continue from the previous result.
+	// A leading - followed by a number is still a negative number.
+	LeadingOperator bool
+	// Units allows units directly following decimal numbers, e.g. 100px + 20px is 120px. Results keep the unit when
+	// all the units match.
+	Units bool
+	// MagnitudeSuffixes treats k, m, g, ki, mi, and gi directly following decimal numbers as multipliers instead of
+	// units, e.g. 10k is 10000 and 2mi is 2097152
+	MagnitudeSuffixes bool
+	// ImplicitMultiplication multiplies a value or ) directly followed by a variable, (, or function, e.g. 2(3 + 4) is
+	// 2 * (3 + 4) and 2$foo is 2 * $foo. Functions need a space, as 2abs would be a unit.
+	ImplicitMultiplication bool
+	// VariablePrefix starts variable names instead of $, e.g. '@' for @foo. It should be a symbol that isn't otherwise
+	// used. Variables are still named with $ in the nodes and the state, so profiles work with any prefix.
+	VariablePrefix rune
+	// StrictConversions only allows a single number in dec(), hex(), bin(), and oct(), e.g. hex(255) but not
+	// hex(1 + 2)
+	StrictConversions bool
+	// MaxInputLen rejects inputs longer than this many bytes before lexing, 0 means unlimited
+	MaxInputLen int
+
 	lexer        *lexer
 	items        []item
 	input        string
 	parenthesis  int
+	absBars      []absBar
 	pos          Pos
 	lastLexerEnd Pos
+	// stopPos is the start of the last item read, where the error is when parsing stops
+	stopPos Pos
+	// negations are the parenthesis levels of groups negated with -, e.g. -(1 + 2), each closed with a ) added after
+	// the group
+	negations []int
+	// compoundAssign is set when the right side of a compound assignment needs its closing parenthesis at the end
+	compoundAssign bool
+	// assignTargets is the number of variables in a parallel assignment like $a, $b = 1, 2, 0 for other input
+	assignTargets int
+}
+
+// absBar is an open |x| absolute value group
+type absBar struct {
+	// parenthesis is the parenthesis level inside the group
+	parenthesis int
+	pos         Pos
 }
 
 var (
 	// ErrorUnexpectedEOF is given when there is an unexpected EOF
 	ErrorUnexpectedEOF = errors.New("unexpected end of input")
+	// ErrorNoValue is given for degenerate expressions that could never give a value, e.g. $foo = or ()
+	ErrorNoValue = errors.New("expression has no value")
+	// ErrorInputTooLong is given when the input is longer than MaxInputLen
+	ErrorInputTooLong = errors.New("input too long")
 	// ErrorInternal is used for unspecified internal errors
 	ErrorInternal = errors.New("internal error while parsing")
 )
 
-func (p *parser) parse() (nodes []Node, err error) {
-	lexer, items := lex(p.input)
-	p.lexer = lexer
+// reset clears the state left over from any previous input
+func (p *Parser) reset(input string) {
+	p.lexer = nil
+	p.items = p.items[:0]
+	p.input = input
 	p.parenthesis = 0
+	p.absBars = p.absBars[:0]
+	p.pos = 0
+	p.lastLexerEnd = 0
+	p.stopPos = 0
+	p.compoundAssign = false
+	p.assignTargets = 0
+	p.negations = p.negations[:0]
+}
+
+func (p *Parser) parse() (nodes []Node, err error) {
+	if p.MaxInputLen > 0 && len(p.input) > p.MaxInputLen {
+		err = fmt.Errorf("%w: %d bytes, the limit is %d", ErrorInputTooLong, len(p.input), p.MaxInputLen)
+		// Nothing is parsed, it stops at the first byte over the limit
+		nodes = []Node{newParsingStopped(Pos(p.MaxInputLen))}
+		return
+	}
+
+	lexer, items := lexWithConfig(p.input, lexConfig{aliases: p.OperatorAliases, variablePrefix: p.VariablePrefix})
+	p.lexer = lexer
 
 	nodes, err = p.readTokens(items)
 
@@ -34,7 +106,13 @@ func (p *parser) parse() (nodes []Node, err error) {
 	}
 
 	if err != nil {
-		nodes = append(nodes, newParsingStopped(p.lastLexerEnd))
+		// Let the lexer finish when parsing stopped early, so its goroutine doesn't leak
+		go func() {
+			for range items {
+			}
+		}()
+
+		nodes = append(nodes, newParsingStopped(p.stopPos))
 	} else if lastType != NodeEOF {
 		nodes = append(nodes, newParsingStopped(p.lastLexerEnd))
 	}
@@ -42,21 +120,27 @@ func (p *parser) parse() (nodes []Node, err error) {
 	return
 }
 
-func (p *parser) nextItem(items chan item) (*item, error) {
+func (p *Parser) nextItem(items chan item) (*item, error) {
 	// If the next item has already been peeked at
 	if p.pos < Pos(len(p.items)) {
 		item := p.items[p.pos]
 		p.pos++
+		p.stopPos = item.pos
 		return &item, nil
 	}
 
 	for item := range items {
-		// Skip spaces, they have no meaning for our parsing
-		if item.typ == itemSpace {
+		// Skip spaces, they have no meaning for our parsing. Statements are split on newlines before parsing, so
+		// within a statement they're just spaces.
+		if item.typ == itemSpace || item.typ == itemNewline {
 			continue
 		}
 
+		p.stopPos = item.pos
 		if item.typ == itemEOF {
+			if len(p.absBars) > 0 {
+				return nil, fmt.Errorf("unexpected end of input, | at pos %d is not closed", p.absBars[len(p.absBars)-1].pos)
+			}
 			if p.parenthesis > 0 {
 				return nil, fmt.Errorf("unexpected end of input, there are unclosed parenthesis")
 			}
@@ -77,16 +161,142 @@ func (p *parser) nextItem(items chan item) (*item, error) {
 	return nil, ErrorInternal
 }
 
-func (p *parser) peek(items chan item) (*item, error) {
+func (p *Parser) peek(items chan item) (*item, error) {
 	start := p.pos
+	stopPos := p.stopPos
 	item, err := p.nextItem(items)
 
 	// We want to back up a bit so this item will get scanned again next call to nextItem()
 	p.pos = start
+	if err == nil {
+		p.stopPos = stopPos
+	}
 
 	return item, err
 }
 
+// constants maps the names of the mathematical constants to their values, they're lexed as text so they can still be
+// used as profile names, e.g. save(e)
+var constants = map[string]float64{
+	"e":       math.E,
+	"phi":     math.Phi,
+	"pi":      math.Pi,
+	"sqrt1_2": 1 / math.Sqrt2,
+	"sqrt2":   math.Sqrt2,
+}
+
+// isConstant checks if the item is the name of a constant
+func isConstant(itm *item) bool {
+	_, ok := constants[itm.val]
+	return itm.typ == itemText && ok
+}
+
+// isNumberItem checks if the item stands for a number, i.e. it's a number or a constant
+func isNumberItem(itm *item) bool {
+	return itm != nil && (itm.typ == itemNumber || isConstant(itm))
+}
+
+// numberLiteral gives the number an item stands for, e.g. 3.141592653589793 for pi
+func numberLiteral(itm *item) string {
+	if isConstant(itm) {
+		return strconv.FormatFloat(constants[itm.val], 'f', -1, 64)
+	}
+	return itm.val
+}
+
+// magnitudeSuffixes are the multipliers used with MagnitudeSuffixes
+var magnitudeSuffixes = map[string]int64{
+	"k":  1e3,
+	"m":  1e6,
+	"g":  1e9,
+	"ki": 1 << 10,
+	"mi": 1 << 20,
+	"gi": 1 << 30,
+}
+
+// multiplyLiteral multiplies a decimal number literal exactly, e.g. 1.5 * 1000 is 1500
+func multiplyLiteral(value string, multiplier int64) (string, error) {
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return "", fmt.Errorf("invalid number %s", value)
+	}
+
+	r.Mul(r, new(big.Rat).SetInt64(multiplier))
+	return ratLiteral(r), nil
+}
+
+// percentLiteral divides a decimal number literal by 100 exactly, e.g. 5 is 0.05
+func percentLiteral(value string) (string, error) {
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return "", fmt.Errorf("invalid number %s", value)
+	}
+
+	r.Quo(r, big.NewRat(100, 1))
+	return ratLiteral(r), nil
+}
+
+// ratLiteral formats the number as a decimal literal
+func ratLiteral(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	f64, _ := r.Float64()
+	return strconv.FormatFloat(f64, 'f', -1, 64)
+}
+
+// checkAssignTarget checks that the variable can be assigned to
+func checkAssignTarget(target VariableNode) error {
+	if target.Name == lastAssignedVariable {
+		return fmt.Errorf("can't assign to %s, it always references the last assigned variable", lastAssignedVariable)
+	}
+	if strings.Contains(target.Name, profileSeparator) {
+		return fmt.Errorf("can't assign to %s, variables in other profiles are read only", target.Name)
+	}
+	return nil
+}
+
+// isTargetList checks if the nodes are only variables separated by commas, e.g. $a, $b before the = of a parallel
+// assignment
+func isTargetList(nodes []Node) bool {
+	if len(nodes)%2 == 0 {
+		return false
+	}
+
+	for i, node := range nodes {
+		if (i%2 == 0 && node.Type() != NodeVariable) || (i%2 == 1 && node.Type() != NodeComma) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPercentAssignment checks for a single decimal number followed by % being assigned, e.g. $rate = 5%
+func isPercentAssignment(nodes []Node) bool {
+	if len(nodes) != 3 || nodes[0].Type() != NodeAssign || nodes[2].Type() != NodeMod {
+		return false
+	}
+
+	num, ok := nodes[1].(NumberNode)
+	return ok && num.System == Dec
+}
+
+// Commonly valid node types on the left, built once as appending to the package level lists could modify them
+var (
+	valueOrRParenNodes    = joinNodeTypes(ValueNodes, []NodeType{NodeRParen})
+	operatorOrPrefixNodes = joinNodeTypes(OperatorNodes, prefixNodes)
+)
+
+// joinNodeTypes combines the lists into a new list, never modifying the originals
+func joinNodeTypes(lists ...[]NodeType) []NodeType {
+	joined := []NodeType{}
+	for _, list := range lists {
+		joined = append(joined, list...)
+	}
+	return joined
+}
+
 // IsNodeType checks if the node of one of the given types
 func IsNodeType(node Node, types []NodeType) bool {
 	t := node.Type()
@@ -109,7 +319,7 @@ func isItemType(item *item, types []ItemType) bool {
 	return false
 }
 
-func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
+func (p *Parser) readTokens(items chan item) (nodes []Node, err error) {
 	nodes = []Node{}
 	for {
 		// Read new item
@@ -124,6 +334,10 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 			return
 		}
 
+		if p.ImplicitMultiplication && p.impliesMultiplication(itm, nodes) {
+			nodes = append(nodes, newOperator(itm.pos, "*"))
+		}
+
 		if itm == nil {
 			/*
 				EOF
@@ -133,20 +347,68 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 			if p.pos >= 1 {
 				if len(nodes) > 0 {
 					left := nodes[len(nodes)-1]
-					validLeftTypes := append(ValueNodes, NodeRParen)
-
-					if !IsNodeType(left, validLeftTypes) {
-						err = ErrorUnexpectedEOF
+					validLeftTypes := valueOrRParenNodes
+
+					if left.Type() == NodeAssign {
+						assign, _ := left.(AssignNode)
+						err = fmt.Errorf("%w, nothing is assigned to %s at pos %d", ErrorNoValue, assign.Target, left.Position())
+					} else if isPercentAssignment(nodes) {
+						// $rate = 5% assigns 0.05, everywhere else % is the modulus
+						num, _ := nodes[1].(NumberNode)
+						num.Value, err = percentLiteral(num.Value)
+						nodes = append(nodes[:1], num)
+					} else if p.compoundAssign && len(nodes) == 4 {
+						assign, _ := nodes[0].(AssignNode)
+						err = fmt.Errorf("%w, nothing is assigned to %s at pos %d", ErrorNoValue, assign.Target, assign.Position())
+					} else if IsNodeType(left, OperatorNodes) {
+						err = fmt.Errorf("%w after operator '%s' at pos %d", ErrorUnexpectedEOF, left.String(), left.Position())
+					} else if !IsNodeType(left, validLeftTypes) {
+						err = fmt.Errorf("%w after %s at pos %d", ErrorUnexpectedEOF, left.String(), left.Position())
+					} else if p.StrictConversions && nodes[0].Type() == NodeSetOutput {
+						err = checkStrictConversion(nodes)
+					} else if p.StrictConversions && len(nodes) > 1 && nodes[1].Type() == NodeSetOutput {
+						err = checkStrictConversion(nodes[1:])
+					} else if p.assignTargets == 0 && len(nodes) > 1 && nodes[1].Type() == NodeComma {
+						err = fmt.Errorf("unexpected , at pos %d, the variables of a parallel assignment must be followed by =. Ex: $a, $b = 1, 2", nodes[1].Position())
+					} else if values := len(splitArgs(nodes[p.assignTargets:])); p.assignTargets > 0 && values != p.assignTargets {
+						err = fmt.Errorf("can't assign %d values to %d variables at pos %d", values, p.assignTargets, nodes[0].Position())
 					}
 				}
 			}
 
+			if p.compoundAssign {
+				nodes = append(nodes, newRParen(Pos(len(p.input))))
+			}
+
 			nodes = append(nodes, newEOF(Pos(len(p.input))))
 			return
 		} else if itm.typ == itemEquals {
 			/*
 				=
+				$a, $b = 1, 2
 			*/
+			if len(nodes) > 1 && isTargetList(nodes) {
+				// Parallel assignment, the variables are replaced with assignments and the commas between them dropped
+				assigns := []Node{}
+				assigned := map[string]bool{}
+				for i := 0; i < len(nodes); i += 2 {
+					target := nodes[i].(VariableNode)
+					if err = checkAssignTarget(target); err != nil {
+						return
+					}
+					if assigned[target.Name] {
+						err = fmt.Errorf("can't assign to %s twice at pos %d", target.Name, target.Position())
+						return
+					}
+					assigned[target.Name] = true
+					assigns = append(assigns, newAssign(target.Position(), target.Name))
+				}
+
+				nodes = assigns
+				p.assignTargets = len(assigns)
+				continue
+			}
+
 			if p.pos != 2 || nodes[0].Type() != NodeVariable {
 				// Equals can only be used to assign to variables, so as the 2nd thing on the line
 				err = fmt.Errorf("equals can only follow a variable name at the very start of the line. Ex: $foo = 1")
@@ -155,7 +417,49 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 
 			// Replace original variable reference with an assignment
 			target := nodes[0].(VariableNode)
+			if err = checkAssignTarget(target); err != nil {
+				return
+			}
+			nodes[0] = newAssign(target.Position(), target.Name)
+		} else if itm.typ == itemCompoundAssign {
+			/*
+				+= -= *= /= //= **= &= |= ^= %= <<= >>=
+			*/
+			if p.pos != 2 || nodes[0].Type() != NodeVariable {
+				err = fmt.Errorf("unexpected %s= at pos %d, compound assignment can only follow a variable name at the very start of the line. Ex: $foo += 1", itm.val, itm.pos)
+				return
+			}
+
+			target := nodes[0].(VariableNode)
+			if err = checkAssignTarget(target); err != nil {
+				return
+			}
+
+			// $foo **= 1 + 1 is $foo = $foo ** (1 + 1), the parenthesis keep precedence and associativity from mixing
+			// the existing value into the right side, and the closing one is added at EOF
 			nodes[0] = newAssign(target.Position(), target.Name)
+			nodes = append(nodes, target, newOperator(itm.pos, itm.val), newLParen(itm.pos))
+			p.compoundAssign = true
+		} else if itm.typ == itemInlineAssign {
+			/*
+				:=
+			*/
+			if !p.InlineAssignment {
+				err = fmt.Errorf("unexpected := at pos %d, inline assignment is not enabled", itm.pos)
+				return
+			}
+
+			if len(nodes) == 0 || nodes[len(nodes)-1].Type() != NodeVariable {
+				err = fmt.Errorf("unexpected := at pos %d, inline assignment must follow a variable name. Ex: ($foo := 1)", itm.pos)
+				return
+			}
+
+			// Replace the variable reference with an inline assignment
+			target := nodes[len(nodes)-1].(VariableNode)
+			if err = checkAssignTarget(target); err != nil {
+				return
+			}
+			nodes[len(nodes)-1] = newInlineAssign(target.Position(), target.Name)
 		} else if itm.typ == itemVariable {
 			/*
 				$foo
@@ -163,12 +467,15 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 			if p.pos != 1 {
 				left := nodes[len(nodes)-1]
 
-				validLeftTypes := append(OperatorNodes, NodeLParen, NodeAssign)
+				validLeftTypes := joinNodeTypes(OperatorNodes, []NodeType{NodeLParen, NodeAssign, NodeComma})
 				if len(nodes) == 1 {
 					validLeftTypes = append(validLeftTypes, prefixNodes...)
 				}
 
 				if !IsNodeType(left, validLeftTypes) {
+					if err = p.absBarError(nodes, itm); err != nil {
+						return
+					}
 					err = fmt.Errorf("unexpected %s at pos %d following %s", itm.val, itm.pos, left.String())
 					return
 				}
@@ -179,41 +486,83 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 		} else if isItemType(itm, []ItemType{itemDec, itemBin, itemOct, itemHex}) {
 			/*
 				Set output mode: dec() bin() oct() hex()
+				$foo = hex(255)
 			*/
-			if p.pos != 1 {
+			afterAssign := len(nodes) == 1 && nodes[0].Type() == NodeAssign
+			if p.pos != 1 && !afterAssign {
 				err = fmt.Errorf("unexpected %s at pos %d, setting output type must be the first thing you do", itm.val, itm.pos)
 				return
 			}
 
 			nodes = append(nodes, newSetOutput(itm.pos, itm.val))
 
+		} else if itm.typ == itemOr && (p.opensAbsBar(nodes) || p.closesAbsBar(nodes)) {
+			/*
+				|-5| as an alternative to abs(-5), disambiguated from bitwise or:
+				- A | where an operator can't be, at the start or after an operator, (, = or similar, opens a group
+				- A | where an operator can be, after a value or ), closes the innermost group when there are no
+				  unclosed parenthesis within it, so |1 | 2| is abs(1) followed by an error, use |(1 | 2)| instead
+				- Any other | is bitwise or, e.g. 3 | |-2| is 3 | abs(-2)
+				The group is parsed as abs( and ) so it's executed like abs()
+			*/
+			if p.opensAbsBar(nodes) {
+				p.parenthesis++
+				p.absBars = append(p.absBars, absBar{parenthesis: p.parenthesis, pos: itm.pos})
+				nodes = append(nodes, newAbs(itm.pos), newLParen(itm.pos))
+			} else {
+				p.parenthesis--
+				p.absBars = p.absBars[:len(p.absBars)-1]
+				nodes = append(nodes, newRParen(itm.pos))
+				nodes = p.closeNegations(nodes)
+			}
 		} else if isItemType(itm, operatorItems) {
 			/*
-				Operators: + - * ** / // & | ^ ~ % << >>
+				Operators: + - * ** / // & | ^ ~ % << >> < <= > >= == !=
 				(and negative numbers)
 			*/
+			var peek *item = nil
+			peeked := false
+
+			// -(1 + 2) is (-1 * (1 + 2)), the closing ) is added after the group with closeNegations
+			if itm.typ == itemSub && p.canNegate(nodes) {
+				peek, err = p.peek(items)
+				if err != nil {
+					return
+				}
+				peeked = true
+
+				if peek != nil && isItemType(peek, []ItemType{itemLParen, itemFunction, itemAbs, itemOr}) {
+					nodes = append(nodes, newLParen(-1), newNumber(-1, "-1", Dec), newOperator(-1, "*"))
+					p.negations = append(p.negations, p.parenthesis+1)
+					continue
+				}
+			}
+
 			// Special handling of - for negative numbers
 			isNegativeNumber := false
 
-			var peek *item = nil
 			if itm.typ == itemSub {
 				if p.pos == 1 {
-					peek, err = p.peek(items)
-					if err != nil {
-						return
+					if !peeked {
+						peek, err = p.peek(items)
+						if err != nil {
+							return
+						}
 					}
-					isNegativeNumber = true
+					isNegativeNumber = !p.LeadingOperator || isNumberItem(peek)
 				} else {
 					left := nodes[len(nodes)-1]
 					// Check for 2 - 1 or $foo - 1
 					if !IsNodeType(left, ValueNodes) {
-						peek, err = p.peek(items)
-						if err != nil {
-							return
+						if !peeked {
+							peek, err = p.peek(items)
+							if err != nil {
+								return
+							}
 						}
 
 						// Is the next item a number?
-						if peek != nil && peek.typ == itemNumber {
+						if isNumberItem(peek) {
 							if p.pos == 1 {
 								isNegativeNumber = true
 							} else {
@@ -225,7 +574,7 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 									$foo = -1 // Prefixes
 									dec(-7)
 								*/
-								validLeftTypes := append(OperatorNodes, prefixNodes...)
+								validLeftTypes := operatorOrPrefixNodes
 								if IsNodeType(left, validLeftTypes) {
 									isNegativeNumber = true
 								}
@@ -237,29 +586,25 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 
 			if peek != nil && isNegativeNumber {
 				// This is a negative number, do number validation
-				value := fmt.Sprintf("-%s", peek.val)
 				if p.pos != 1 {
 					left := nodes[len(nodes)-1]
-					validLeftTypes := append(OperatorNodes, prefixNodes...)
+					validLeftTypes := operatorOrPrefixNodes
 
 					if !IsNodeType(left, validLeftTypes) {
-						err = fmt.Errorf("unexpected %s at pos %d, looks like a negative number that doesn't make sense here", value, itm.pos)
+						err = fmt.Errorf("unexpected -%s at pos %d, looks like a negative number that doesn't make sense here", peek.val, itm.pos)
 						return
 					}
 				}
-				nodes = append(nodes, newNumber(itm.pos, value, parseNumberSystem(peek.val)))
+				nodes = append(nodes, newNumber(itm.pos, "-"+numberLiteral(peek), parseNumberSystem(peek.val)))
 				p.pos++ // Skip peeked item, it's been parsed
 			} else {
 				// Is an operator valid here - typically needs a value on the left (and right, but that will be checked later), or rparen
 				if p.pos == 1 {
-					err = fmt.Errorf("unexpected %s at pos %d", itm.val, itm.pos)
-					return
-				}
-
-				left := nodes[len(nodes)-1]
-				validLeftTypes := append(ValueNodes, NodeRParen)
-
-				if !IsNodeType(left, validLeftTypes) {
+					if !p.LeadingOperator {
+						err = fmt.Errorf("unexpected %s at pos %d", itm.val, itm.pos)
+						return
+					}
+				} else if left := nodes[len(nodes)-1]; !IsNodeType(left, valueOrRParenNodes) {
 					err = fmt.Errorf("unexpected %s at pos %d, operators should follow numbers, variables, or closing parenthesis", itm.val, itm.pos)
 					return
 				}
@@ -278,9 +623,12 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 
 			if p.pos != 1 {
 				left := nodes[len(nodes)-1]
-				validLeftTypes := append(OperatorNodes, prefixNodes...)
+				validLeftTypes := operatorOrPrefixNodes
 
 				if !IsNodeType(left, validLeftTypes) {
+					if err = p.absBarError(nodes, itm); err != nil {
+						return
+					}
 					err = fmt.Errorf("unexpected %s at pos %d, looks like a negative number that doesn't make sense here", itm.val, itm.pos)
 					return
 				}
@@ -288,7 +636,47 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 
 			// Number should look like a legitimate number from lexing, just need to figure out system
 			nodes = append(nodes, newNumber(itm.pos, itm.val, parseNumberSystem(itm.val)))
-		} else if itm.typ == itemClear {
+		} else if itm.typ == itemUnit {
+			/*
+				Units directly following numbers:
+				100px
+			*/
+			left := nodes[len(nodes)-1]
+			if left.Type() != NodeNumber {
+				err = fmt.Errorf("unexpected %s at pos %d, units must directly follow a number", itm.val, itm.pos)
+				return
+			}
+			if _, ok := constants[itm.val]; ok {
+				// 2e would be too easy to mistake for 2 * e or an unfinished 2e3
+				err = fmt.Errorf("unexpected %s at pos %d, units can't be named like constants, use * to multiply with %s", itm.val, itm.pos, itm.val)
+				return
+			}
+
+			num, _ := left.(NumberNode)
+			if multiplier, ok := magnitudeSuffixes[itm.val]; ok && p.MagnitudeSuffixes {
+				if num.System != Dec {
+					// 0755k would multiply the octal digits as if they were decimal
+					err = fmt.Errorf("unexpected %s at pos %d, magnitude suffixes only work with decimal numbers, got %s", itm.val, itm.pos, num.Value)
+					return
+				}
+				num.Value, err = multiplyLiteral(num.Value, multiplier)
+				if err != nil {
+					return
+				}
+			} else if p.Units {
+				num.Unit = itm.val
+			} else {
+				err = fmt.Errorf("unexpected %s at pos %d, units are not enabled, use * to multiply", itm.val, itm.pos)
+				return
+			}
+			nodes[len(nodes)-1] = num
+		} else if isItemType(itm, []ItemType{itemClear, itemWhere, itemHelp, itemInspect}) {
+			/*
+				clear()
+				where()
+				help()
+				inspect()
+			*/
 			invalidErr := fmt.Errorf("unexpected %s at pos %d, when used the input should be only: %s()", itm.val, itm.pos, itm.val)
 
 			if p.pos != 1 {
@@ -313,20 +701,27 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 				return
 			}
 
-			// clear()
 			if p.items[1].typ != itemLParen || p.items[2].typ != itemRParen {
 				err = invalidErr
 				return
 			}
 
 			// Since we just consumed all the items, we need to whip some magic or get an internal error
-			nodes = append(nodes, newClear(itm.pos))
+			if itm.typ == itemClear {
+				nodes = append(nodes, newClear(itm.pos))
+			} else if itm.typ == itemWhere {
+				nodes = append(nodes, newWhere(itm.pos))
+			} else if itm.typ == itemInspect {
+				nodes = append(nodes, newInspect(itm.pos))
+			} else {
+				nodes = append(nodes, newHelp(itm.pos))
+			}
 			nodes = append(nodes, newEOF(Pos(len(p.input))))
 			return
 		} else if isItemType(itm, []ItemType{itemSave, itemLoad}) {
 			/*
 				save(name)
-				load(name)
+				load("name")
 			*/
 
 			invalidErr := fmt.Errorf("unexpected %s at pos %d, when used the input should be only: %s(name)", itm.val, itm.pos, itm.val)
@@ -354,13 +749,24 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 			}
 
 			// save|load followed by ( name )
-			if p.items[1].typ != itemLParen || p.items[2].typ != itemText || p.items[3].typ != itemRParen {
+			if p.items[1].typ != itemLParen || !isItemType(&p.items[2], []ItemType{itemText, itemString}) || p.items[3].typ != itemRParen {
 				err = invalidErr
 				return
 			}
 
+			// Quoted names could otherwise point outside the storage path
+			name := p.items[2].val
+			if name == "" {
+				err = fmt.Errorf("%w at pos %d", ErrorProfileNameRequired, p.items[2].pos)
+				return
+			}
+			if strings.ContainsAny(name, `/\`) || strings.Trim(name, ".") == "" {
+				err = fmt.Errorf("invalid name %q at pos %d, names can't contain / or \\", name, p.items[2].pos)
+				return
+			}
+
 			// Since we just consumed all the items, we need to whip some magic or get an internal error
-			nodes = append(nodes, newDiskOperation(itm.pos, itm.val, p.items[2].val))
+			nodes = append(nodes, newDiskOperation(itm.pos, itm.val, name))
 			nodes = append(nodes, newEOF(Pos(len(p.input))))
 			return
 		} else if itm.typ == itemLParen {
@@ -372,11 +778,10 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 			// Allowed following abs() dec() hex() bin() oct() = ( and operators
 			if p.pos != 1 && len(nodes) > 0 {
 				left := nodes[len(nodes)-1]
-				validLeftTypes := append(OperatorNodes, prefixNodes...)
-				validLeftTypes = append(validLeftTypes, NodeAbs)
+				validLeftTypes := joinNodeTypes(OperatorNodes, prefixNodes, []NodeType{NodeAbs, NodeFunction})
 
 				if !IsNodeType(left, validLeftTypes) {
-					err = fmt.Errorf("unexpected ( at pos %d, should be following abs, dec, hex, bin, oct, =, operators, or other (s", itm.pos)
+					err = fmt.Errorf("unexpected ( at pos %d, should be following functions, dec, hex, bin, oct, =, operators, or other (s", itm.pos)
 					return
 				}
 			}
@@ -398,10 +803,18 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 				return
 			}
 
+			if p.closesAbsBar(nodes) {
+				err = fmt.Errorf("unexpected ) at pos %d, | at pos %d must be closed first", itm.pos, p.absBars[len(p.absBars)-1].pos)
+				return
+			}
+
 			left := nodes[len(nodes)-1]
-			validLeftTypes := append(ValueNodes, NodeRParen)
+			validLeftTypes := joinNodeTypes(valueOrRParenNodes, []NodeType{NodeString})
 
-			if !IsNodeType(left, validLeftTypes) {
+			if left.Type() == NodeLParen {
+				err = fmt.Errorf("%w, empty parenthesis at pos %d", ErrorNoValue, left.Position())
+				return
+			} else if !IsNodeType(left, validLeftTypes) {
 				err = fmt.Errorf("unexpected ) at pos %d, should be following numbers, variables, or other )s", itm.pos)
 				return
 			}
@@ -410,13 +823,37 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 			p.parenthesis--
 
 			nodes = append(nodes, newRParen(itm.pos))
+			nodes = p.closeNegations(nodes)
+		} else if itm.typ == itemComma {
+			/*
+				modpow(2, 10, 1000)
+				$a, $b = 1, 2
+			*/
+			if isTargetList(nodes) {
+				nodes = append(nodes, newComma(itm.pos))
+				continue
+			}
+
+			separatesValues := p.assignTargets > 0 && p.parenthesis == 0
+			if p.pos == 1 || !(p.inFunction(nodes) || separatesValues) {
+				err = fmt.Errorf("unexpected , at pos %d, commas can only separate function arguments", itm.pos)
+				return
+			}
+
+			left := nodes[len(nodes)-1]
+			if !IsNodeType(left, joinNodeTypes(valueOrRParenNodes, []NodeType{NodeString})) {
+				err = fmt.Errorf("unexpected , at pos %d, should be following numbers, variables, or )s", itm.pos)
+				return
+			}
+
+			nodes = append(nodes, newComma(itm.pos))
 		} else if itm.typ == itemAbs {
 			/*
 				abs()
 			*/
 			if p.pos != 1 {
 				left := nodes[len(nodes)-1]
-				validLeftTypes := append(OperatorNodes, prefixNodes...)
+				validLeftTypes := operatorOrPrefixNodes
 
 				if !IsNodeType(left, validLeftTypes) {
 					err = fmt.Errorf("unexpected abs() at pos %d, may follow operators, (, or =", itm.pos)
@@ -425,6 +862,41 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 			}
 
 			nodes = append(nodes, newAbs(itm.pos))
+		} else if itm.typ == itemFunction {
+			/*
+				float_bits() and other functions
+			*/
+			if p.pos != 1 {
+				left := nodes[len(nodes)-1]
+				validLeftTypes := operatorOrPrefixNodes
+
+				if !IsNodeType(left, validLeftTypes) {
+					err = fmt.Errorf("unexpected %s() at pos %d, may follow operators, (, or =", itm.val, itm.pos)
+					return
+				}
+			}
+
+			nodes = append(nodes, newFunction(itm.pos, itm.val))
+		} else if itm.typ == itemString {
+			/*
+				Strings as function arguments, e.g. convert("ff", 16, 10)
+			*/
+			if p.pos == 1 || !p.inFunction(nodes) || !IsNodeType(nodes[len(nodes)-1], []NodeType{NodeLParen, NodeComma}) {
+				err = fmt.Errorf("unexpected %s at pos %d, strings can only be function arguments", quoteString(itm.val), itm.pos)
+				return
+			}
+
+			nodes = append(nodes, newString(itm.pos, itm.val))
+		} else if isConstant(itm) {
+			/*
+				Constants: pi e phi sqrt2 sqrt1_2
+			*/
+			if p.pos != 1 && !IsNodeType(nodes[len(nodes)-1], operatorOrPrefixNodes) {
+				err = fmt.Errorf("unexpected %s at pos %d, constants should follow operators, (, or =", itm.val, itm.pos)
+				return
+			}
+
+			nodes = append(nodes, newNumber(itm.pos, numberLiteral(itm), Dec))
 		} else if itm.typ == itemText {
 			err = fmt.Errorf("unexpected %s at pos %d", itm.val, itm.pos)
 			nodes = append(nodes, newEOF(Pos(len(p.input))))
@@ -437,12 +909,99 @@ func (p *parser) readTokens(items chan item) (nodes []Node, err error) {
 	}
 }
 
-// Parse any zappac lang string
-func Parse(input string) (nodes []Node, err error) {
-	p := &parser{
-		input: input,
+// opensAbsBar checks if a | at this point opens a |x| group instead of being bitwise or
+func (p *Parser) opensAbsBar(nodes []Node) bool {
+	return len(nodes) == 0 || IsNodeType(nodes[len(nodes)-1], operatorOrPrefixNodes)
+}
+
+// checkStrictConversion checks that a conversion like hex(255) only has a single number for StrictConversions
+func checkStrictConversion(nodes []Node) error {
+	if len(nodes) == 4 && nodes[1].Type() == NodeLParen && nodes[2].Type() == NodeNumber && nodes[3].Type() == NodeRParen {
+		return nil
+	}
+
+	name := strings.ToLower(nodes[0].String())
+	return fmt.Errorf("%s() at pos %d only accepts a single number, e.g. %s(255)", name, nodes[0].Position(), name)
+}
+
+// canNegate checks if a - at this point negates what follows rather than subtracts from it
+func (p *Parser) canNegate(nodes []Node) bool {
+	if p.pos == 1 {
+		return !p.LeadingOperator
 	}
+	return IsNodeType(nodes[len(nodes)-1], operatorOrPrefixNodes)
+}
+
+// closeNegations adds the closing ) for the negated groups that just ended
+func (p *Parser) closeNegations(nodes []Node) []Node {
+	for len(p.negations) > 0 && p.negations[len(p.negations)-1] == p.parenthesis+1 {
+		p.negations = p.negations[:len(p.negations)-1]
+		nodes = append(nodes, newRParen(-1))
+	}
+	return nodes
+}
+
+// impliesMultiplication checks if the item starts a value right after another value, e.g. the ( in 2(3 + 4)
+func (p *Parser) impliesMultiplication(itm *item, nodes []Node) bool {
+	if itm == nil || !(isItemType(itm, []ItemType{itemVariable, itemLParen, itemFunction, itemAbs}) || isConstant(itm)) {
+		return false
+	}
+	return len(nodes) > 0 && IsNodeType(nodes[len(nodes)-1], valueOrRParenNodes)
+}
+
+// closesAbsBar checks if a | or ) at this point is where the innermost |x| group should be closed
+func (p *Parser) closesAbsBar(nodes []Node) bool {
+	if len(p.absBars) == 0 || p.absBars[len(p.absBars)-1].parenthesis != p.parenthesis {
+		return false
+	}
+	return len(nodes) > 0 && IsNodeType(nodes[len(nodes)-1], valueOrRParenNodes)
+}
+
+// absBarError explains a value right after the | closing a |x| group, e.g. 2 in |1 | 2| where the | meant as bitwise
+// or closed the group instead
+func (p *Parser) absBarError(nodes []Node, itm *item) error {
+	if len(nodes) == 0 || nodes[len(nodes)-1].Type() != NodeRParen {
+		return nil
+	}
+
+	pos := nodes[len(nodes)-1].Position()
+	if pos < 0 || int(pos) >= len(p.input) || p.input[pos] != '|' {
+		return nil
+	}
+	return fmt.Errorf("unexpected %s at pos %d, | at pos %d closes the |...| group, use parenthesis for bitwise or inside it, e.g. |(1 | 2)|", itm.val, itm.pos, pos)
+}
+
+// inFunction checks if the innermost open parenthesis belongs to a function call
+func (p *Parser) inFunction(nodes []Node) bool {
+	depth := 0
+	for i := len(nodes) - 1; i >= 0; i-- {
+		switch nodes[i].Type() {
+		case NodeRParen:
+			depth++
+		case NodeLParen:
+			if depth == 0 {
+				return i > 0 && nodes[i-1].Type() == NodeFunction
+			}
+			depth--
+		}
+	}
+	return false
+}
+
+// NewParser creates a new Parser, which can be reused to avoid allocations
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+// Parse parses the input, resetting any state from previous calls
+func (p *Parser) Parse(input string) (nodes []Node, err error) {
+	p.reset(input)
 
 	nodes, err = p.parse()
 	return
 }
+
+// Parse any zappac lang string
+func Parse(input string) (nodes []Node, err error) {
+	return NewParser().Parse(input)
+}
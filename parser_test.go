@@ -1,6 +1,7 @@
 package zappaclang
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -20,8 +21,14 @@ type parserTest struct {
 var parserTests = []parserTest{
 	{"empty", "", []simpleNode{}},
 	{"clear", "clear()", []simpleNode{{typ: NodeClear, val: "clear()"}}},
+	{"where", "where()", []simpleNode{{typ: NodeWhere, val: "where()"}}},
+	{"help", "help()", []simpleNode{{typ: NodeHelp, val: "help()"}}},
+	{"inspect", "inspect()", []simpleNode{{typ: NodeInspect, val: "inspect()"}}},
 	{"save", "save(foobar)", []simpleNode{{typ: NodeSave, val: "save(foobar)"}}},
 	{"load", "load(foobar)", []simpleNode{{typ: NodeLoad, val: "load(foobar)"}}},
+	{"load quoted", "load(\"foo bar\")", []simpleNode{{typ: NodeLoad, val: "load(\"foo bar\")"}}},
+	{"save escaped", `save("a\"b")`, []simpleNode{{typ: NodeSave, val: `save("a\"b")`}}},
+	{"save quoted plain name", "save(\"foo\")", []simpleNode{{typ: NodeSave, val: "save(foo)"}}},
 	{"bin", "bin(16 ** 2)", []simpleNode{
 		{typ: NodeSetOutput, val: "Bin"},
 		{typ: NodeLParen, val: "("},
@@ -30,6 +37,27 @@ var parserTests = []parserTest{
 		{typ: NodeNumber, val: "2"},
 		{typ: NodeRParen, val: ")"},
 	}},
+	{"compound assignment", "$foo **= 2 ** 3", []simpleNode{
+		{typ: NodeAssign, val: "$foo ="},
+		{typ: NodeVariable, val: "$foo"},
+		{typ: NodeExp, val: "**"},
+		{typ: NodeLParen, val: "("},
+		{typ: NodeNumber, val: "2"},
+		{typ: NodeExp, val: "**"},
+		{typ: NodeNumber, val: "3"},
+		{typ: NodeRParen, val: ")"},
+	}},
+	{"negated group", "-(1+2)", []simpleNode{
+		{typ: NodeLParen, val: "("},
+		{typ: NodeNumber, val: "-1"},
+		{typ: NodeMult, val: "*"},
+		{typ: NodeLParen, val: "("},
+		{typ: NodeNumber, val: "1"},
+		{typ: NodeAdd, val: "+"},
+		{typ: NodeNumber, val: "2"},
+		{typ: NodeRParen, val: ")"},
+		{typ: NodeRParen, val: ")"},
+	}},
 	{"complex", "$foo = ((1 - 2) ** abs(-7)) // b100", []simpleNode{
 		{typ: NodeAssign, val: "$foo ="},
 		{typ: NodeLParen, val: "("},
@@ -69,6 +97,55 @@ var parserTests = []parserTest{
 		{typ: NodeSub, val: "-"},
 		{typ: NodeNumber, val: "b001"},
 	}},
+	{"abs bars", "|-5|", []simpleNode{
+		{typ: NodeAbs, val: "abs"},
+		{typ: NodeLParen, val: "("},
+		{typ: NodeNumber, val: "-5"},
+		{typ: NodeRParen, val: ")"},
+	}},
+	{"abs bars with or", "3 | |-2|", []simpleNode{
+		{typ: NodeNumber, val: "3"},
+		{typ: NodeOr, val: "|"},
+		{typ: NodeAbs, val: "abs"},
+		{typ: NodeLParen, val: "("},
+		{typ: NodeNumber, val: "-2"},
+		{typ: NodeRParen, val: ")"},
+	}},
+	{"abs bars with or inside", "|(1 | 2)|", []simpleNode{
+		{typ: NodeAbs, val: "abs"},
+		{typ: NodeLParen, val: "("},
+		{typ: NodeLParen, val: "("},
+		{typ: NodeNumber, val: "1"},
+		{typ: NodeOr, val: "|"},
+		{typ: NodeNumber, val: "2"},
+		{typ: NodeRParen, val: ")"},
+		{typ: NodeRParen, val: ")"},
+	}},
+}
+
+func TestParseInlineAssignment(t *testing.T) {
+	p := NewParser()
+	p.InlineAssignment = true
+
+	nodes, err := p.Parse("($x := 5) + $x")
+	if err != nil {
+		t.Errorf("got\n\t%v", err)
+		return
+	}
+
+	expected := []simpleNode{
+		{typ: NodeLParen, val: "("},
+		{typ: NodeInlineAssign, val: "$x :="},
+		{typ: NodeNumber, val: "5"},
+		{typ: NodeRParen, val: ")"},
+		{typ: NodeAdd, val: "+"},
+		{typ: NodeVariable, val: "$x"},
+		{typ: NodeEOF, val: ""},
+	}
+
+	if !parsedEqual(nodes, expected, false) {
+		t.Errorf("got\n\t%+v\nexpected\n\t%+v", nodes, expected)
+	}
 }
 
 func parsedEqual(i1 []Node, i2 []simpleNode, checkPos bool) bool {
@@ -95,13 +172,7 @@ func TestParse(t *testing.T) {
 		nodes, err := Parse(test.input)
 		elapsed := time.Since(start)
 
-		// eofPos := Pos(0)
-		if len(nodes) > 0 {
-			if nodes[len(nodes)-1].Type() == NodeEOF {
-				// eofPos = nodes[len(nodes)-1].Position()
-				nodes = nodes[:len(nodes)-1]
-			}
-		}
+		nodes = TrimTerminators(nodes)
 
 		expected := []string{}
 		for _, si := range test.nodes {
@@ -118,8 +189,230 @@ func TestParse(t *testing.T) {
 			return
 		}
 
-		// fmt.Printf("len() %d EOF @ %d\n", len(test.input), eofPos)
-
 		t.Log(test.name, fmt.Sprintf("OK in %s", elapsed))
 	}
 }
+
+func TestParserReuse(t *testing.T) {
+	p := NewParser()
+
+	for _, test := range parserTests {
+		// Leave the parser in a broken state between each valid input
+		_, err := p.Parse("((1 +")
+		if err == nil {
+			t.Errorf("%s: expected an error for unclosed parenthesis", test.name)
+			return
+		}
+
+		nodes, err := p.Parse(test.input)
+		if err != nil {
+			t.Errorf("%s: got\n\t%v", test.name, err)
+			return
+		}
+
+		nodes = TrimTerminators(nodes)
+
+		if !parsedEqual(nodes, test.nodes, false) {
+			t.Errorf("%s: got\n\t%+v\nexpected\n\t%+v", test.name, nodes, test.nodes)
+			return
+		}
+	}
+}
+
+func TestParseKeepsNodeLists(t *testing.T) {
+	operatorNodes := fmt.Sprint(OperatorNodes)
+	valueNodes := fmt.Sprint(ValueNodes)
+	prefix := fmt.Sprint(prefixNodes)
+
+	inputs := []string{"$foo = -1", "(1 + $bar) * -2", "abs(-3) // 2", "hex(-7 + b01)", "sign(1) - 1", "1 +", "(("}
+	for i := 0; i < 100; i++ {
+		for _, input := range inputs {
+			_, _ = Parse(input)
+		}
+	}
+
+	if fmt.Sprint(OperatorNodes) != operatorNodes {
+		t.Errorf("OperatorNodes changed: got\n\t%v\nexpected\n\t%s", OperatorNodes, operatorNodes)
+	}
+	if fmt.Sprint(ValueNodes) != valueNodes {
+		t.Errorf("ValueNodes changed: got\n\t%v\nexpected\n\t%s", ValueNodes, valueNodes)
+	}
+	if fmt.Sprint(prefixNodes) != prefix {
+		t.Errorf("prefixNodes changed: got\n\t%v\nexpected\n\t%s", prefixNodes, prefix)
+	}
+}
+
+func TestParseDanglingOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 +", "unexpected end of input after operator '+' at pos 2"},
+		{"1+", "unexpected end of input after operator '+' at pos 1"},
+		{"$foo ** ", "unexpected end of input after operator '**' at pos 5"},
+		{"(1 + 2) //", "unexpected end of input after operator '//' at pos 8"},
+		{"0xff <<", "unexpected end of input after operator '<<' at pos 5"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.input)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: got\n\t%v\nexpected\n\t%s", test.input, err, test.expected)
+		}
+		if !errors.Is(err, ErrorUnexpectedEOF) {
+			t.Errorf("%s: expected ErrorUnexpectedEOF, got %v", test.input, err)
+		}
+	}
+}
+
+func TestParseNoValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"$foo =", "expression has no value, nothing is assigned to $foo at pos 0"},
+		{"dec()", "expression has no value, empty parenthesis at pos 3"},
+		{"()", "expression has no value, empty parenthesis at pos 0"},
+		{"1 + (())", "expression has no value, empty parenthesis at pos 5"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.input)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: got\n\t%v\nexpected\n\t%s", test.input, err, test.expected)
+		}
+		if !errors.Is(err, ErrorNoValue) {
+			t.Errorf("%s: expected ErrorNoValue, got %v", test.input, err)
+		}
+	}
+}
+
+func TestParseVariablePrefix(t *testing.T) {
+	p := NewParser()
+	p.VariablePrefix = '@'
+
+	nodes, err := p.Parse("@foo = @bar * 2")
+	if err != nil {
+		t.Fatalf("got\n\t%v", err)
+	}
+
+	expected := "[$foo = $bar * 2 ]"
+	if fmt.Sprint(nodes) != expected {
+		t.Errorf("got\n\t%v\nexpected\n\t%s", nodes, expected)
+	}
+
+	if _, err := p.Parse("$foo"); err == nil || err.Error() != "Unexpected $ at pos 0" {
+		t.Errorf("got\n\t%v", err)
+	}
+
+	zs := NewZappacState("")
+	zs.clear()
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"@width = 10", "10"},
+		{"@width * 2", "20"},
+		{"@@ + 1", "11"},
+	})
+}
+
+func TestParseStrictConversions(t *testing.T) {
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"bin(1+2)", "b11"},
+		{"hex(0xf + 1)", "0x10"},
+	})
+
+	p := NewParser()
+	p.StrictConversions = true
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"hex(255)", "0xff"},
+		{"dec(0xff)", "255"},
+		{"bin(-2)", "-b10"},
+		{"oct(8)", "010"},
+		{"1 + 2", "3"},
+		{"bin(1+2)", "bin() at pos 0 only accepts a single number, e.g. bin(255)"},
+		{"hex((255))", "hex() at pos 0 only accepts a single number, e.g. hex(255)"},
+		{"dec($foo)", "dec() at pos 0 only accepts a single number, e.g. dec(255)"},
+		{"oct(8) + 1", "oct() at pos 0 only accepts a single number, e.g. oct(255)"},
+		{"$foo = hex(255)", "0xff"},
+		{"$foo = hex(255 + 1)", "hex() at pos 7 only accepts a single number, e.g. hex(255)"},
+	})
+}
+
+func TestParseImplicitMultiplication(t *testing.T) {
+	p := NewParser()
+	p.ImplicitMultiplication = true
+
+	nodes, err := p.Parse("2(3+4)")
+	if err != nil {
+		t.Fatalf("got\n\t%v", err)
+	}
+	if unparsed := Unparse(nodes); unparsed != "2 * (3 + 4)" {
+		t.Errorf("got\n\t%s\nexpected\n\t2 * (3 + 4)", unparsed)
+	}
+
+	zs := NewZappacState("")
+	zs.clear()
+
+	runExecTests(t, zs, []execTestCase{
+		{"$x = 5", "5"},
+		{"2(3+4)", "unexpected ( at pos 1, should be following functions, dec, hex, bin, oct, =, operators, or other (s"},
+	})
+
+	runExecTestsWithParser(t, zs, p, []execTestCase{
+		{"2(3+4) == 14", "1"},
+		{"2$x", "10"},
+		{"2$x == 2*$x", "1"},
+		{"(1 + 1)(2 + 2)", "8"},
+		{"$x$x", "25"},
+		{"$x(1 + 1)", "10"},
+		{"3 abs(-2)", "6"},
+		{"-2(3)", "-6"},
+		{"1 + 2(3)", "7"},
+		{"hex(2(8))", "0x10"},
+		{"modpow(2, 3(2), 100)", "64"},
+		{"$y = 2(3)", "6"},
+		{"2 3", "unexpected 3 at pos 2, looks like a negative number that doesn't make sense here"},
+	})
+}
+
+func TestParseMaxInputLen(t *testing.T) {
+	p := NewParser()
+	p.MaxInputLen = 5
+
+	if _, err := p.Parse("1 + 2"); err != nil {
+		t.Errorf("expected input at the limit to parse, got %v", err)
+	}
+
+	nodes, err := p.Parse("1 + 23")
+	if !errors.Is(err, ErrorInputTooLong) {
+		t.Errorf("expected ErrorInputTooLong, got %v", err)
+	}
+	if err != nil && err.Error() != "input too long: 6 bytes, the limit is 5" {
+		t.Errorf("unexpected error message %s", err)
+	}
+	if len(nodes) != 1 || nodes[0].Type() != NodeParsingStopped || nodes[0].Position() != 5 {
+		t.Errorf("expected only ParsingStopped at pos 5, got %+v", nodes)
+	}
+}
+
+func TestParseInvalidProfileNames(t *testing.T) {
+	for _, input := range []string{`save("")`, `load("../secret")`, `save("a\\b")`, `load("..")`} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParseEmptyProfileName(t *testing.T) {
+	for _, input := range []string{`save("")`, `load("")`} {
+		_, err := Parse(input)
+		if !errors.Is(err, ErrorProfileNameRequired) {
+			t.Errorf("%s: expected ErrorProfileNameRequired, got %v", input, err)
+		}
+		if err != nil && err.Error() != "profile name required at pos 5" {
+			t.Errorf("%s: unexpected error message %s", input, err)
+		}
+	}
+}
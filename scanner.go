@@ -0,0 +1,88 @@
+package zappaclang
+
+import "errors"
+
+// scannerLookahead is how many bytes past its position the lexer may look at without reading them,
+// e.g. when checking for ** after *
+const scannerLookahead = 2
+
+// ErrorScannerClosed is given when writing to a closed Scanner
+var ErrorScannerClosed = errors.New("scanner is closed")
+
+// Token is a lexed token given by Scanner
+type Token struct {
+	Type  ItemType
+	Pos   Pos // The starting position, in bytes, of this token in the input
+	End   Pos // The ending position
+	Value string
+}
+
+func (t Token) String() string {
+	return item{t.Type, t.Pos, t.End, t.Value}.String()
+}
+
+// Scanner tokenizes input incrementally as it is written, giving tokens once more input can no longer change them
+type Scanner struct {
+	lexer  *lexer
+	queue  []item // complete items not yet given by Next
+	closed bool
+}
+
+// NewScanner creates a Scanner with no input
+func NewScanner() *Scanner {
+	return &Scanner{
+		lexer: newLexer("", lexConfig{}),
+	}
+}
+
+// Write appends more input to be tokenized
+func (s *Scanner) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, ErrorScannerClosed
+	}
+
+	s.lexer.input += string(p)
+	s.lexer.len = Pos(len(s.lexer.input))
+	return len(p), nil
+}
+
+// Close marks the end of input, allowing the last tokens and EOF to be given
+func (s *Scanner) Close() error {
+	s.closed = true
+	return nil
+}
+
+// Next gives the next complete token, ok is false when more input or Close is needed first, or after EOF or an error
+func (s *Scanner) Next() (token Token, ok bool) {
+	l := s.lexer
+
+	for len(s.queue) == 0 {
+		if l.state == nil {
+			return
+		}
+
+		// Run a single state, and roll it back if it got too close to the end of the input, as more input could
+		// still change the result
+		state, start, pos, input := l.state, l.start, l.pos, l.input
+		l.atEOF = false
+		l.hitEOF = false
+
+		next := state(l)
+
+		if !s.closed && (next == nil || l.hitEOF || int(l.pos)+scannerLookahead > len(input)) {
+			l.state, l.start, l.pos, l.input = state, start, pos, input
+			l.len = Pos(len(input))
+			l.pending = nil
+			return
+		}
+
+		l.state = next
+		s.queue = append(s.queue, l.pending...)
+		l.pending = nil
+	}
+
+	i := s.queue[0]
+	s.queue = s.queue[1:]
+
+	return Token{Type: i.typ, Pos: i.pos, End: i.end, Value: i.val}, true
+}
@@ -0,0 +1,79 @@
+package zappaclang
+
+import "testing"
+
+func TestScanner(t *testing.T) {
+	for _, test := range lexTests {
+		expected := collect(&test)
+
+		for chunkSize := 1; chunkSize <= 4; chunkSize++ {
+			s := NewScanner()
+			var items []item
+
+			for start := 0; start < len(test.input); start += chunkSize {
+				end := start + chunkSize
+				if end > len(test.input) {
+					end = len(test.input)
+				}
+
+				_, _ = s.Write([]byte(test.input[start:end]))
+				for token, ok := s.Next(); ok; token, ok = s.Next() {
+					items = append(items, item{token.Type, token.Pos, token.End, token.Value})
+				}
+			}
+
+			_ = s.Close()
+			for token, ok := s.Next(); ok; token, ok = s.Next() {
+				items = append(items, item{token.Type, token.Pos, token.End, token.Value})
+			}
+
+			if !equal(items, expected, true) {
+				t.Errorf("%s in chunks of %d: got\n\t%+v\nexpected\n\t%v", test.name, chunkSize, items, expected)
+			}
+		}
+	}
+}
+
+func TestScannerWaitsForUnambiguousTokens(t *testing.T) {
+	s := NewScanner()
+	_, _ = s.Write([]byte("12 *"))
+
+	token, ok := s.Next()
+	if !ok || token.Value != "12" {
+		t.Errorf("expected 12, got %v %v", token, ok)
+	}
+
+	// The space could still grow and * could still become **
+	if token, ok := s.Next(); ok {
+		t.Errorf("expected no token yet, got %v", token)
+	}
+
+	_, _ = s.Write([]byte("* 1e"))
+	if token, ok := s.Next(); !ok || token.Type != itemSpace {
+		t.Errorf("expected a space, got %v %v", token, ok)
+	}
+	if token, ok := s.Next(); !ok || token.Type != itemExp {
+		t.Errorf("expected **, got %v %v", token, ok)
+	}
+	if token, ok := s.Next(); !ok || token.Type != itemSpace {
+		t.Errorf("expected a space, got %v %v", token, ok)
+	}
+
+	// 1e could still become 1e3
+	if token, ok := s.Next(); ok {
+		t.Errorf("expected no token yet, got %v", token)
+	}
+
+	_, _ = s.Write([]byte("3"))
+	_ = s.Close()
+
+	if token, ok := s.Next(); !ok || token.Value != "1e3" {
+		t.Errorf("expected 1e3, got %v %v", token, ok)
+	}
+	if token, ok := s.Next(); !ok || token.Type != itemEOF {
+		t.Errorf("expected EOF, got %v %v", token, ok)
+	}
+	if _, err := s.Write([]byte("1")); err != ErrorScannerClosed {
+		t.Errorf("expected ErrorScannerClosed, got %v", err)
+	}
+}
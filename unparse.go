@@ -0,0 +1,89 @@
+package zappaclang
+
+import "strings"
+
+// Unparse turns parsed nodes back into zappac lang with canonical spacing, e.g. 1+2*( 3) becomes 1 + 2 * (3)
+func Unparse(nodes []Node) string {
+	return unparse(nodes, "", false)
+}
+
+// UnparsePreservingSpacing turns nodes parsed from input back into zappac lang, keeping the spacing the input used
+// around each token. Where the original spacing can't be found, e.g. for operator aliases, canonical spacing is used.
+func UnparsePreservingSpacing(nodes []Node, input string) string {
+	return unparse(nodes, input, true)
+}
+
+func unparse(nodes []Node, input string, preserveSpacing bool) string {
+	var sb strings.Builder
+	var prev Node
+	prevEnd := 0
+	prevInInput := false
+
+	for _, node := range nodes {
+		if IsNodeType(node, []NodeType{NodeEOF, NodeParsingStopped}) {
+			break
+		}
+
+		token := unparseToken(node)
+		start := int(node.Position())
+		// Tokens written differently in the input, e.g. with operator aliases, have no reliable spacing around them
+		inInput := preserveSpacing && start >= prevEnd && start <= len(input) && strings.HasPrefix(input[start:], token)
+
+		if prev != nil {
+			gap := canonicalGap(prev, node)
+
+			// Only keep the original spacing when the same symbols are around it, so it can't change the meaning
+			if prevInInput && inInput {
+				original := input[prevEnd:start]
+				if strings.TrimSpace(original) == strings.TrimSpace(gap) {
+					gap = original
+				}
+			}
+
+			sb.WriteString(gap)
+		}
+
+		sb.WriteString(token)
+		prev = node
+		prevEnd = start + len(token)
+		prevInInput = inInput
+	}
+
+	return sb.String()
+}
+
+// unparseToken gives the canonical text of the node, without what canonicalGap adds after it
+func unparseToken(node Node) string {
+	switch n := node.(type) {
+	case AssignNode:
+		return n.Target
+	case InlineAssignNode:
+		return n.Target
+	case SetOutputNode:
+		return strings.ToLower(n.Output.String())
+	}
+
+	return node.String()
+}
+
+// canonicalGap gives the text between two nodes with canonical spacing
+func canonicalGap(prev, next Node) string {
+	switch {
+	case prev.Type() == NodeAssign && next.Type() == NodeAssign:
+		return ", "
+	case prev.Type() == NodeAssign:
+		return " = "
+	case prev.Type() == NodeInlineAssign:
+		return " := "
+	case IsNodeType(next, []NodeType{NodeComma, NodeRParen}):
+		return ""
+	case IsNodeType(prev, []NodeType{NodeLParen, NodeSetOutput, NodeAbs, NodeFunction}):
+		return ""
+	case prev.Type() == NodeComma:
+		return " "
+	case IsNodeType(prev, OperatorNodes) || IsNodeType(next, OperatorNodes):
+		return " "
+	}
+
+	return ""
+}
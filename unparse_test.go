@@ -0,0 +1,62 @@
+package zappaclang
+
+import "testing"
+
+type unparseTest struct {
+	input     string
+	canonical string
+	preserved string
+}
+
+var unparseTests = []unparseTest{
+	{"1+2*3", "1 + 2 * 3", "1+2*3"},
+	{"1 +2*  3", "1 + 2 * 3", "1 +2*  3"},
+	{"$foo=( 1+-2 )**2", "$foo = (1 + -2) ** 2", "$foo=( 1+-2 )**2"},
+	{"$foo   =   0xff", "$foo = 0xff", "$foo   =   0xff"},
+	{"$a,$b=1,( 2 )", "$a, $b = 1, (2)", "$a,$b=1,( 2 )"},
+	{`convert( "ff",16,10 )`, `convert("ff", 16, 10)`, `convert( "ff",16,10 )`},
+	{"hex( 10px //3 )", "hex(10px // 3)", "hex( 10px //3 )"},
+	{"modpow(2,10 ,  100)", "modpow(2, 10, 100)", "modpow(2,10 ,  100)"},
+	{"abs(-1)<  $x", "abs(-1) < $x", "abs(-1)<  $x"},
+	{"save(foo)", "save(foo)", "save(foo)"},
+	{"clear()", "clear()", "clear()"},
+	{"where()", "where()", "where()"},
+	{"help()", "help()", "help()"},
+	{"", "", ""},
+}
+
+func TestUnparse(t *testing.T) {
+	p := NewParser()
+	p.Units = true
+	for _, test := range unparseTests {
+		nodes, err := p.Parse(test.input)
+		if err != nil {
+			t.Errorf("%s: %v", test.input, err)
+			continue
+		}
+
+		if result := Unparse(nodes); result != test.canonical {
+			t.Errorf("%s canonical: got\n\t%q\nexpected\n\t%q", test.input, result, test.canonical)
+		}
+		if result := UnparsePreservingSpacing(nodes, test.input); result != test.preserved {
+			t.Errorf("%s preserved: got\n\t%q\nexpected\n\t%q", test.input, result, test.preserved)
+		}
+	}
+}
+
+func TestUnparsePreservingSpacingWithAliases(t *testing.T) {
+	p := NewParser()
+	p.OperatorAliases = DefaultOperatorAliases
+
+	input := "2×3 +  1"
+	nodes, err := p.Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The spacing around × can't be matched to *, so it falls back to canonical spacing
+	expected := "2 * 3 +  1"
+	if result := UnparsePreservingSpacing(nodes, input); result != expected {
+		t.Errorf("got\n\t%q\nexpected\n\t%q", result, expected)
+	}
+}